@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+/*algorithmOrder is the row order of the README's comparison table*/
+var algorithmOrder = []string{"NONE", "LRU", "LFU", "FIFO", "LCR", "LECAR", "CALECAR"}
+
+func algorithmRank(cacheType string) int {
+	for i, algorithm := range algorithmOrder {
+		if algorithm == cacheType {
+			return i
+		}
+	}
+	return len(algorithmOrder)
+}
+
+/*commas groups the digits of a cost the way the README table does*/
+func commas(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		digits = digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+/*compareReports merges reports from several runs into the markdown
+table from the README, one block per dataset label*/
+func compareReports(filenames []string) {
+	reports := []*runReport{}
+	for _, filename := range filenames {
+		report, err := readReport(filename)
+		if err != nil {
+			fmt.Println("ERROR reading report "+filename+": ", err)
+			os.Exit(-1)
+		}
+		reports = append(reports, report)
+	}
+	writeComparison(os.Stdout, reports)
+}
+
+/*writeComparison keeps labels in the order they first appear, and
+sorts each label's runs into the README's algorithm order*/
+func writeComparison(out io.Writer, reports []*runReport) {
+	labels := []string{}
+	byLabel := map[string][]*runReport{}
+	for _, report := range reports {
+		if _, ok := byLabel[report.Label]; !ok {
+			labels = append(labels, report.Label)
+		}
+		byLabel[report.Label] = append(byLabel[report.Label], report)
+	}
+	fmt.Fprintln(out, "| DATASET | ALGORITHM |      COST     | COST-hitrate |")
+	fmt.Fprintln(out, "------------------------------------------------------")
+	for i, label := range labels {
+		if i > 0 {
+			fmt.Fprintln(out, "---------------------------------------")
+		}
+		reports := byLabel[label]
+		sort.SliceStable(reports, func(i, j int) bool {
+			return algorithmRank(reports[i].CacheType) < algorithmRank(reports[j].CacheType)
+		})
+		for _, report := range reports {
+			fmt.Fprintf(out, "| %-7s | %-9s | %13s | %.3f |\n", label, report.CacheType, commas(report.Cost), report.CostHitRate)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommas(t *testing.T) {
+	cases := map[int]string{
+		0:          "0",
+		999:        "999",
+		1000:       "1,000",
+		123456:     "123,456",
+		1234567:    "1,234,567",
+		-1234:      "-1,234",
+		-123456789: "-123,456,789",
+	}
+	for n, want := range cases {
+		if got := commas(n); got != want {
+			t.Errorf("%d: got %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	dir := t.TempDir()
+	runs := []struct {
+		label     string
+		cacheType string
+		cost      int
+		saved     int
+		format    string
+	}{
+		{"lfu", "LCR", 600000, 400000, "json"},
+		{"lru", "LRU", 1000, 3000, "csv"},
+		{"lfu", "NONE", 1000000, 0, "csv"},
+		{"lfu", "CUSTOM", 123, 0, "json"},
+		{"lfu", "LRU", 900000, 100000, "json"},
+	}
+	reports := []*runReport{}
+	for i, run := range runs {
+		report := &runReport{Label: run.label, CacheType: run.cacheType}
+		report.Cost = run.cost
+		report.CostSaved = run.saved
+		report.updateRates()
+		filename := filepath.Join(dir, run.label+string(rune('a'+i))+"."+run.format)
+		if err := writeReport(report, run.format, filename); err != nil {
+			t.Fatal(err)
+		}
+		read, err := readReport(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, read)
+	}
+	var out bytes.Buffer
+	writeComparison(&out, reports)
+	want := `| DATASET | ALGORITHM |      COST     | COST-hitrate |
+------------------------------------------------------
+| lfu     | NONE      |     1,000,000 | 0.000 |
+| lfu     | LRU       |       900,000 | 0.100 |
+| lfu     | LCR       |       600,000 | 0.400 |
+| lfu     | CUSTOM    |           123 | 0.000 |
+---------------------------------------
+| lru     | LRU       |         1,000 | 0.750 |
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
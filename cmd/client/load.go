@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/client"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+/*loadStats is what one worker saw during the measurement window*/
+type loadStats struct {
+	tally
+	classes   classTallies
+	latencies []time.Duration
+}
+
+/*loadMode is on whenever the flags ask for more than one
+request in flight, a target rate, or a timed run*/
+func loadMode(conf *clientConf) bool {
+	return conf.workers > 1 || conf.rate > 0 || conf.duration > 0
+}
+
+/*traceKey is a key along with its position in the trace, and
+when it's due if the trace is being paced*/
+type traceKey struct {
+	index int
+	key   string
+	due   time.Time
+}
+
+/*feedKeys streams the keyfiles into the channel, starting
+over from the first file when the run is timed and the
+trace runs out before the clock does*/
+func feedKeys(conf *clientConf, pace *pacer, keys chan<- traceKey, stop <-chan struct{}) {
+	defer close(keys)
+	fileList := strings.Split(*conf.keyfile, ",")
+	index := 0
+	for {
+		passStart := index
+		for _, keyFile := range fileList {
+			keysF, err := trace.Open(keyFile, conf.format)
+			if err != nil {
+				fmt.Println("ERROR reading keyfile: ", err)
+				os.Exit(-1)
+			}
+			for {
+				request, err := keysF.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					fmt.Println("ERROR reading row of keyfile: ", err)
+					os.Exit(-1)
+				}
+				if request.IsEvent() {
+					// a recording's flushes and resets aren't requests to send
+					continue
+				}
+				index++
+				select {
+				case keys <- traceKey{index: index, key: request.Key, due: pace.due(request.Timestamp)}:
+				case <-stop:
+					keysF.Close()
+					return
+				}
+			}
+			pace.nextFile()
+			keysF.Close()
+		}
+		// stdin can only be read once, so there's nothing to loop over
+		if conf.duration == 0 || index == passStart {
+			return
+		}
+	}
+}
+
+/*scheduleArrivals emits the intended start time of each request
+for an open-loop run, with exponential gaps between them so the
+arrivals form a poisson process at the target rate*/
+func scheduleArrivals(rate float64, arrivals chan<- time.Time, stop <-chan struct{}) {
+	defer close(arrivals)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	next := time.Now()
+	for {
+		next = next.Add(time.Duration(rng.ExpFloat64() / rate * float64(time.Second)))
+		time.Sleep(time.Until(next))
+		select {
+		case arrivals <- next:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func runWorker(c *client.Client, conf *clientConf, keys <-chan traceKey, arrivals <-chan time.Time, measureFrom time.Time, check *verifier, stats *loadStats) {
+	for {
+		start := time.Now()
+		if arrivals != nil {
+			// latency counts from when the request was due, so time
+			// spent queued behind slow requests isn't hidden
+			arrival, ok := <-arrivals
+			if !ok {
+				return
+			}
+			start = arrival
+		}
+		next, ok := <-keys
+		if !ok {
+			return
+		}
+		if !next.due.IsZero() {
+			// a paced trace is open-loop too, with its own arrivals
+			time.Sleep(time.Until(next.due))
+			start = next.due
+		}
+		key := next.key
+		result, err := c.FetchResult(context.Background(), key)
+		latency := time.Since(start)
+		if err != nil && err != client.ErrNotFound {
+			fmt.Println("ERROR fetching "+key+": ", err)
+			continue
+		}
+		if check != nil {
+			check.check(next.index, result)
+		}
+		if start.Before(measureFrom) {
+			continue
+		}
+		stats.add(result)
+		if conf.classifier != nil && err == nil {
+			stats.classes.add(conf.classifier.Class(key, result.Cost+result.Saved), result)
+		}
+		stats.latencies = append(stats.latencies, latency)
+	}
+}
+
+/*mergeWorkers adds up what every worker saw, sorting the
+latencies so percentiles can be read off them*/
+func mergeWorkers(workerStats []*loadStats) loadStats {
+	total := loadStats{classes: classTallies{}}
+	for _, stats := range workerStats {
+		total.merge(stats.tally)
+		total.classes.merge(stats.classes)
+		total.latencies = append(total.latencies, stats.latencies...)
+	}
+	sort.Slice(total.latencies, func(i, j int) bool { return total.latencies[i] < total.latencies[j] })
+	return total
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(p * float64(len(sorted)-1))
+	return sorted[index]
+}
+
+/*runLoad drives the server with several workers at once, either
+closed-loop (each worker fires as soon as its last request is
+answered) or open-loop at a target rate.  Requests that start
+during the warm-up are sent but not measured*/
+func runLoad(conf *clientConf) {
+	c := buildClient(conf)
+	defer c.Close()
+	stop := make(chan struct{})
+	var check *verifier
+	if conf.verify {
+		check = newVerifier(conf, c)
+	}
+	keys := make(chan traceKey, conf.workers*2)
+	go feedKeys(conf, newPacer(conf.speed), keys, stop)
+	var arrivals chan time.Time
+	if conf.rate > 0 {
+		arrivals = make(chan time.Time, 10000)
+		go scheduleArrivals(conf.rate, arrivals, stop)
+	}
+	began := time.Now()
+	measureFrom := began.Add(conf.warmup)
+	if conf.duration > 0 {
+		timer := time.AfterFunc(conf.warmup+conf.duration, func() { close(stop) })
+		defer timer.Stop()
+	}
+	workerStats := make([]*loadStats, conf.workers)
+	var workers sync.WaitGroup
+	for i := 0; i < conf.workers; i++ {
+		workerStats[i] = &loadStats{classes: classTallies{}}
+		workers.Add(1)
+		go func(stats *loadStats) {
+			defer workers.Done()
+			runWorker(c, conf, keys, arrivals, measureFrom, check, stats)
+		}(workerStats[i])
+	}
+	if conf.duration > 0 {
+		// a timed run ends on the clock, not when workers finish
+		<-stop
+	}
+	workers.Wait()
+	elapsed := time.Since(measureFrom)
+	if conf.duration > 0 {
+		elapsed = conf.duration
+	}
+	total := mergeWorkers(workerStats)
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(total.Requests) / elapsed.Seconds()
+	} else {
+		// the trace ran out before the warm-up did
+		elapsed = 0
+		fmt.Println("WARNING: the trace ended during the -warmup, so nothing was measured")
+	}
+	fmt.Println("WORKERS:", conf.workers, "TARGET RATE:", conf.rate)
+	fmt.Println("MEASURED REQUESTS:", total.Requests, "OVER", elapsed)
+	fmt.Println("THROUGHPUT:", throughput, "req/s")
+	fmt.Println("LATENCY p50:", percentile(total.latencies, 0.50),
+		"p95:", percentile(total.latencies, 0.95),
+		"p99:", percentile(total.latencies, 0.99))
+	printTally(total.tally)
+	printClasses(total.classes)
+	if check != nil {
+		check.finish()
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/client"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	cases := []struct {
+		latencies []time.Duration
+		p         float64
+		want      time.Duration
+	}{
+		{nil, 0.5, 0},
+		{[]time.Duration{}, 0.99, 0},
+		{[]time.Duration{7}, 0, 7},
+		{[]time.Duration{7}, 1, 7},
+		{sorted, 0, 1},
+		{sorted, 0.5, 5},
+		{sorted, 0.95, 9},
+		{sorted, 0.99, 9},
+		{sorted, 1, 10},
+	}
+	for _, c := range cases {
+		if got := percentile(c.latencies, c.p); got != c.want {
+			t.Errorf("p%v of %v: got %v, want %v", c.p*100, c.latencies, got, c.want)
+		}
+	}
+}
+
+func TestMergeWorkers(t *testing.T) {
+	first := &loadStats{classes: classTallies{}}
+	first.add(client.Result{Key: "key1", Value: "val1", Cost: 10})
+	first.classes.add("cheap", client.Result{Key: "key1", Value: "val1", Cost: 10})
+	first.latencies = []time.Duration{30, 10}
+	second := &loadStats{classes: classTallies{}}
+	second.add(client.Result{Key: "key1", Value: "val1", Saved: 10, Hit: true})
+	second.add(client.Result{Key: "key3", Value: "val3", Cost: 30})
+	second.classes.add("cheap", client.Result{Key: "key1", Value: "val1", Saved: 10, Hit: true})
+	second.classes.add("dear", client.Result{Key: "key3", Value: "val3", Cost: 30})
+	second.latencies = []time.Duration{20, 40}
+	idle := &loadStats{classes: classTallies{}}
+
+	total := mergeWorkers([]*loadStats{first, second, idle})
+	want := tally{Requests: 3, Hits: 1, Cost: 40, CostSaved: 10, Bytes: 12, BytesHit: 4,
+		HitRate: 1.0 / 3, ByteHitRate: 1.0 / 3, CostHitRate: 0.2}
+	if total.tally != want {
+		t.Errorf("got %+v, want %+v", total.tally, want)
+	}
+	if len(total.classes) != 2 || total.classes["cheap"].Requests != 2 || total.classes["cheap"].Hits != 1 ||
+		total.classes["dear"].Requests != 1 || total.classes["dear"].Cost != 30 {
+		t.Errorf("classes merged wrong: cheap %+v, dear %+v", total.classes["cheap"], total.classes["dear"])
+	}
+	for i, latency := range []time.Duration{10, 20, 30, 40} {
+		if total.latencies[i] != latency {
+			t.Fatalf("latencies should come out sorted, got %v", total.latencies)
+		}
+	}
+	// merging leaves the workers' own numbers alone
+	if first.Requests != 1 || len(first.classes) != 1 || first.classes["cheap"].Requests != 1 {
+		t.Errorf("merging changed a worker's stats: %+v", first.tally)
+	}
+	if empty := mergeWorkers(nil); empty.Requests != 0 || percentile(empty.latencies, 0.5) != 0 {
+		t.Errorf("no workers should merge to nothing, got %+v", empty.tally)
+	}
+}
+
+func TestScheduleArrivals(t *testing.T) {
+	arrivals := make(chan time.Time, 10000)
+	stop := make(chan struct{})
+	start := time.Now()
+	go scheduleArrivals(1000, arrivals, stop)
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	count := 0
+	last := start
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case arrival, ok := <-arrivals:
+			if !ok {
+				// 1000/s for half a second is 500, give or take about 22
+				if count < 350 || count > 650 {
+					t.Errorf("got %d arrivals in 500ms at 1000/s", count)
+				}
+				return
+			}
+			if arrival.Before(last) {
+				t.Fatalf("arrival %d at %v came before the one ahead of it at %v", count, arrival, last)
+			}
+			last = arrival
+			count++
+		case <-timeout:
+			t.Fatal("arrivals weren't closed after stop")
+		}
+	}
+}
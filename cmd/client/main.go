@@ -1,8 +1,7 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -10,87 +9,213 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/JohnCGriffin/overflow"
+	"github.com/evizitei/lcr-cache/pkg/address"
+	"github.com/evizitei/lcr-cache/pkg/classes"
+	"github.com/evizitei/lcr-cache/pkg/client"
+	"github.com/evizitei/lcr-cache/pkg/trace"
 )
 
 type clientConf struct {
-	host    string
-	keyfile *string
-	port    int
-	verbose bool
-}
-
-type queryResult struct {
-	value string
-	cost  int
+	host       string
+	keyfile    *string
+	format     string
+	port       int
+	socket     string
+	batchSize  int
+	protocol   string
+	timeout    time.Duration
+	retries    int
+	workers    int
+	rate       float64
+	warmup     time.Duration
+	duration   time.Duration
+	report     string
+	reportFile string
+	label      string
+	classifier *classes.Classifier
+	verify     bool
+	dataFile   string
+	speed      float64
+	verbose    bool
 }
 
 func parseArgs() *clientConf {
 	keyFile := flag.String("keyfile", "./data/client/traffic_set_baseline.csv", "file with series of keys to fetch")
+	traceFormat := flag.String("trace_format", "keys", "format of the keyfile, one of ("+strings.Join(trace.Formats, ", ")+")")
 	verbose := flag.Bool("verbose", false, "if you want lots of output")
+	host := flag.String("host", "localhost", "host the cache server is listening on")
+	port := flag.Int("port", 1234, "port the cache server is listening on")
+	socket := flag.String("socket", "", "unix domain socket path to use instead of host/port")
+	listen := flag.String("listen", "", "address the server listens on, host:port or unix:/path/to.sock, instead of -host/-port/-socket")
+	batchSize := flag.Int("batch_size", 1, "number of keys to send in each mfetch request (1 sends plain fetches)")
+	protocol := flag.String("protocol", "text", "text, or binary to talk to the server's -binary_port")
+	timeout := flag.Duration("timeout", 10*time.Second, "how long to wait for each request")
+	retries := flag.Int("retries", 2, "how many times to retry a request that failed on the network")
+	workers := flag.Int("workers", 1, "number of concurrent workers sending requests")
+	rate := flag.Float64("rate", 0, "target requests per second with poisson arrivals (0 runs closed-loop)")
+	warmup := flag.Duration("warmup", 0, "how long to send requests before measuring them")
+	duration := flag.Duration("duration", 0, "how long to measure for, looping the trace if needed (0 runs the trace once)")
+	report := flag.String("report", "", "write a structured report of the run, json or csv")
+	reportFile := flag.String("report_file", "", "where to write the report (default ./client_report.<format>)")
+	label := flag.String("label", "", "dataset name for the report (default named after the keyfiles)")
+	keyClasses := flag.String("classes", "", "break results down by key class: a key,class CSV, cost and/or frequency, comma separated")
+	verify := flag.Bool("verify", false, "check every returned value against the dataset, exiting non-zero on a mismatch")
+	speed := flag.Float64("speed", 0, "replay at the trace's timestamps sped up this many times, 1 being original speed (0 ignores them and goes as fast as possible)")
+	dataFile := flag.String("data_file", "./data/test_set_1.csv", "dataset the server was started with, for -verify")
 	flag.Parse()
+	if *listen != "" {
+		var err error
+		*host, *port, *socket, err = address.Split(*listen)
+		if err != nil {
+			fmt.Println("ERROR parsing -listen: ", err)
+			os.Exit(-1)
+		}
+	}
+	var classifier *classes.Classifier
+	if *keyClasses != "" {
+		var err error
+		classifier, err = classes.New(*keyClasses, strings.Split(*keyFile, ","), *traceFormat)
+		if err != nil {
+			fmt.Println("ERROR reading key classes: ", err)
+			os.Exit(-1)
+		}
+	}
+	if *speed > 0 && *rate > 0 {
+		fmt.Println("ERROR: -speed paces requests by the trace, -rate by poisson arrivals, pick one")
+		os.Exit(-1)
+	}
+	if *report != "" && *report != "json" && *report != "csv" {
+		fmt.Println("ERROR: -report must be json or csv")
+		os.Exit(-1)
+	}
 	return &clientConf{
-		keyfile: keyFile,
-		port:    1234,
-		host:    "localhost",
-		verbose: *verbose,
+		keyfile:    keyFile,
+		format:     *traceFormat,
+		port:       *port,
+		host:       *host,
+		socket:     *socket,
+		batchSize:  *batchSize,
+		protocol:   *protocol,
+		timeout:    *timeout,
+		retries:    *retries,
+		workers:    *workers,
+		rate:       *rate,
+		warmup:     *warmup,
+		duration:   *duration,
+		report:     *report,
+		reportFile: *reportFile,
+		label:      *label,
+		classifier: classifier,
+		verify:     *verify,
+		dataFile:   *dataFile,
+		speed:      *speed,
+		verbose:    *verbose,
 	}
 }
 
-func queryKey(conf *clientConf, key string) queryResult {
-	conn, err := net.Dial("tcp", conf.host+":"+strconv.Itoa(conf.port))
+/*serverAddress prefers a unix socket to host/port when one is configured*/
+func serverAddress(conf *clientConf) (string, string) {
+	if conf.socket != "" {
+		return "unix", conf.socket
+	}
+	return "tcp", net.JoinHostPort(conf.host, strconv.Itoa(conf.port))
+}
+
+func buildClient(conf *clientConf) *client.Client {
+	network, address := serverAddress(conf)
+	return client.New(client.Config{
+		Network:  network,
+		Address:  address,
+		Protocol: conf.protocol,
+		Timeout:  conf.timeout,
+		Retries:  conf.retries,
+		PoolSize: conf.workers,
+	})
+}
+
+func queryKey(c *client.Client, key string) client.Result {
+	result, err := c.FetchResult(context.Background(), key)
+	if err == client.ErrNotFound {
+		fmt.Println("No Entry For Key: " + key)
+	} else if err != nil {
+		fmt.Println("ERROR fetching "+key+": ", err)
+		os.Exit(-1)
+	}
+	return result
+}
+
+func queryBatch(c *client.Client, keys []string) []client.Result {
+	results, err := c.FetchBatch(context.Background(), keys)
 	if err != nil {
-		fmt.Println("ERROR dialing server: ", err)
+		fmt.Println("ERROR fetching batch: ", err)
 		os.Exit(-1)
 	}
-	conn.Write([]byte("fetch," + key))
-	connBuff := bufio.NewReader(conn)
-	result := queryResult{}
-	for {
-		response, err := connBuff.ReadString('\n')
-		response = strings.Replace(response, "\n", "", -1)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Println("ERROR parsing connection: ", err)
-			os.Exit(-1)
-		}
-		if strings.Contains(response, "VALUE:") {
-			responseParts := strings.Split(response, ":")
-			result.value = responseParts[1]
-		} else if strings.Contains(response, "COST:") {
-			responseParts := strings.Split(response, ":")
-			cost, err := strconv.Atoi(responseParts[1])
-			if err != nil {
-				fmt.Println("ERROR parsing cost: ", err, response)
-				os.Exit(-1)
-			}
-			result.cost = cost
-		} else {
-			fmt.Println("Unsure how to parse response line: ", response)
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Println("ERROR from server for key "+result.Key+": ", result.Err)
 		}
 	}
-	conn.Close()
-	return result
+	return results
 }
 
 func queryTrafficPattern(conf *clientConf) {
-	accumulatedCost := 0
-	totalRequests := 0
-	cacheServedRequests := 0
+	c := buildClient(conf)
+	defer c.Close()
 	fileList := strings.Split(*conf.keyfile, ",")
-	keyIndex := 0
+	report := &runReport{Label: conf.label, Traces: fileList, Classes: classTallies{}}
+	if report.Label == "" {
+		report.Label = traceLabel(fileList)
+	}
+	var check *verifier
+	if conf.verify {
+		check = newVerifier(conf, c)
+	}
+	var currentFile *fileReport
+	var lastInterval tally
+	record := func(result client.Result) {
+		report.add(result)
+		if check != nil {
+			check.check(report.Requests, result)
+		}
+		currentFile.add(result)
+		if conf.classifier != nil && result.Err == nil {
+			report.Classes.add(conf.classifier.Class(result.Key, result.Cost+result.Saved), result)
+		}
+		if conf.verbose {
+			fmt.Println("QUERY RESULT: key->" + result.Key +
+				", val->" + result.Value +
+				", cost->" + strconv.Itoa(result.Cost) +
+				", saved->" + strconv.Itoa(result.Saved))
+		}
+		if report.Requests%10000 == 0 {
+			fmt.Println("KEY ", report.Requests, " CURRENT ", report.Cost, "HITRATE", report.HitRate)
+			report.Intervals = append(report.Intervals, report.tally.since(lastInterval))
+			lastInterval = report.tally
+		}
+	}
+	pace := newPacer(conf.speed)
+	var lag time.Duration
+	batch := []string{}
+	flushBatch := func() {
+		if len(batch) == 0 {
+			return
+		}
+		for _, result := range queryBatch(c, batch) {
+			record(result)
+		}
+		batch = batch[:0]
+	}
 	for _, keyFile := range fileList {
-		keysF, err := os.OpenFile(keyFile, os.O_RDONLY, 0666)
+		keysF, err := trace.Open(keyFile, conf.format)
 		if err != nil {
 			fmt.Println("ERROR reading keyfile: ", err)
 			os.Exit(-1)
 		}
-		reader := csv.NewReader(keysF)
+		currentFile = &fileReport{File: keyFile}
 		for {
-			row, err := reader.Read()
+			request, err := keysF.Read()
 			if err == io.EOF {
 				break
 			}
@@ -98,32 +223,90 @@ func queryTrafficPattern(conf *clientConf) {
 				fmt.Println("ERROR reading row of keyfile: ", err)
 				os.Exit(-1)
 			}
-			key := row[0]
-			result := queryKey(conf, key)
-			totalRequests++
-			if result.cost == 0 {
-				cacheServedRequests++
+			if request.IsEvent() {
+				// a recording's flushes and resets aren't requests to send
+				continue
+			}
+			key := request.Key
+			if due := pace.due(request.Timestamp); !due.IsZero() {
+				if time.Until(due) > 0 {
+					// don't hold batched keys back while waiting
+					flushBatch()
+					time.Sleep(time.Until(due))
+				} else if time.Since(due) > lag {
+					lag = time.Since(due)
+				}
 			}
-			if conf.verbose {
-				fmt.Println("QUERY RESULT: key->" + key +
-					", val->" + result.value +
-					", cost->" + strconv.Itoa(result.cost))
+			if conf.batchSize <= 1 {
+				record(queryKey(c, key))
+				continue
 			}
-			keyIndex++
-			if keyIndex%10000 == 0 {
-				hitrate := float64(cacheServedRequests) / float64(totalRequests)
-				fmt.Println("KEY ", keyIndex, " CURRENT ", accumulatedCost, "HITRATE", hitrate)
+			batch = append(batch, key)
+			if len(batch) >= conf.batchSize {
+				flushBatch()
 			}
-			accumulatedCost = overflow.Addp(accumulatedCost, result.cost)
 		}
+		// batches don't straddle keyfiles, so each file's numbers are its own
+		flushBatch()
+		pace.nextFile()
+		keysF.Close()
+		report.Files = append(report.Files, *currentFile)
 	}
-	fmt.Println("TRAFFIC COST: ", accumulatedCost)
-	hitrate := float64(cacheServedRequests) / float64(totalRequests)
-	fmt.Println("HIT RATE:", hitrate)
+	printTally(report.tally)
+	printClasses(report.Classes)
+	if conf.speed > 0 {
+		fmt.Println("MAX LAG BEHIND TRACE:", lag)
+	}
+	if check != nil {
+		defer check.finish()
+	}
+	if conf.report == "" {
+		return
+	}
+	stats, err := serverStats(c)
+	if err != nil {
+		fmt.Println("ERROR fetching server stats: ", err)
+		os.Exit(-1)
+	}
+	report.CacheType = stats["CACHE"]
+	report.CacheSize, _ = strconv.Atoi(stats["SIZE"])
+	err = writeReport(report, conf.report, reportFilename(conf))
+	if err != nil {
+		fmt.Println("ERROR writing report: ", err)
+		os.Exit(-1)
+	}
+}
+
+func printTally(t tally) {
+	fmt.Println("TRAFFIC COST: ", t.Cost)
+	fmt.Println("COST SAVED: ", t.CostSaved)
+	fmt.Println("HIT RATE:", t.HitRate)
+	fmt.Println("BYTE HIT RATE:", t.ByteHitRate)
+	fmt.Println("COST HIT RATE:", t.CostHitRate)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		compareReports(os.Args[2:])
+		return
+	}
 	conf := parseArgs()
-	queryTrafficPattern(conf)
+	if loadMode(conf) && conf.report != "" {
+		fmt.Println("ERROR: -report only covers trace runs, not load runs")
+		os.Exit(-1)
+	}
+	if loadMode(conf) && conf.batchSize > 1 {
+		fmt.Println("ERROR: -batch_size only covers trace runs, load runs send one key per request")
+		os.Exit(-1)
+	}
+	if !loadMode(conf) && conf.warmup > 0 {
+		fmt.Println("ERROR: -warmup only covers load runs, trace runs measure every request")
+		os.Exit(-1)
+	}
+	if loadMode(conf) {
+		runLoad(conf)
+	} else {
+		queryTrafficPattern(conf)
+	}
 	fmt.Println("Done!")
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+)
+
+/*pacer spaces requests out by their trace timestamps, sped up
+speed times.  A file's timestamps start over from its own first
+request, so each file (and each loop of the trace) picks up where
+the last one left off*/
+type pacer struct {
+	speed  float64
+	began  time.Time
+	offset time.Duration
+	last   time.Duration
+}
+
+func newPacer(speed float64) *pacer {
+	return &pacer{speed: speed, began: time.Now()}
+}
+
+/*due is when a request with this timestamp should be sent.  A pacer
+with no speed sends everything right away*/
+func (p *pacer) due(timestamp time.Duration) time.Time {
+	if p.speed <= 0 {
+		return time.Time{}
+	}
+	p.last = p.offset + timestamp
+	return p.began.Add(time.Duration(float64(p.last) / p.speed))
+}
+
+func (p *pacer) nextFile() {
+	p.offset = p.last
+}
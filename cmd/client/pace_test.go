@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+	began := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		speed float64
+		// timestamps of each file's requests, from its own start
+		files [][]time.Duration
+		// due times as offsets from when the pacer began
+		want []time.Duration
+	}{
+		{"original speed", 1, [][]time.Duration{{0, time.Second, 3 * time.Second}},
+			[]time.Duration{0, time.Second, 3 * time.Second}},
+		{"sped up", 10, [][]time.Duration{{0, time.Second, 3 * time.Second}},
+			[]time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond}},
+		{"slowed down", 0.5, [][]time.Duration{{time.Second, 2 * time.Second}},
+			[]time.Duration{2 * time.Second, 4 * time.Second}},
+		{"next file picks up where the last left off", 2,
+			[][]time.Duration{{0, 4 * time.Second}, {0, 2 * time.Second}, {time.Second}},
+			[]time.Duration{0, 2 * time.Second, 2 * time.Second, 3 * time.Second, 3500 * time.Millisecond}},
+		{"an empty file changes nothing", 1,
+			[][]time.Duration{{time.Second}, {}, {time.Second}},
+			[]time.Duration{time.Second, 2 * time.Second}},
+	}
+	for _, c := range cases {
+		p := &pacer{speed: c.speed, began: began}
+		got := []time.Duration{}
+		for _, file := range c.files {
+			for _, timestamp := range file {
+				got = append(got, p.due(timestamp).Sub(began))
+			}
+			p.nextFile()
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestUnpaced(t *testing.T) {
+	p := newPacer(0)
+	for _, timestamp := range []time.Duration{0, time.Hour} {
+		if due := p.due(timestamp); !due.IsZero() {
+			t.Errorf("with no speed, %v should be due right away, got %v", timestamp, due)
+		}
+	}
+	p.nextFile()
+	if due := p.due(time.Second); !due.IsZero() {
+		t.Errorf("with no speed, the next file should be due right away, got %v", due)
+	}
+}
+
+func TestPacerStartsNow(t *testing.T) {
+	before := time.Now()
+	p := newPacer(1)
+	after := time.Now()
+	due := p.due(time.Second)
+	if due.Before(before.Add(time.Second)) || due.After(after.Add(time.Second)) {
+		t.Errorf("a request 1s in should be due 1s after the pacer was made, got %v after", due.Sub(before))
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JohnCGriffin/overflow"
+	"github.com/evizitei/lcr-cache/pkg/client"
+)
+
+/*tally accumulates what the client saw over some requests.  The
+server says what each hit saved, so the cost hit rate comes
+straight out of one run instead of a comparison with NONE*/
+type tally struct {
+	Requests    int     `json:"requests"`
+	Hits        int     `json:"hits"`
+	Cost        int     `json:"cost"`
+	CostSaved   int     `json:"cost_saved"`
+	Bytes       int     `json:"bytes"`
+	BytesHit    int     `json:"bytes_hit"`
+	HitRate     float64 `json:"hit_rate"`
+	ByteHitRate float64 `json:"byte_hit_rate"`
+	CostHitRate float64 `json:"cost_hit_rate"`
+}
+
+func ratio(part int, whole int) float64 {
+	if whole == 0 {
+		return 0.0
+	}
+	return float64(part) / float64(whole)
+}
+
+func (t *tally) add(result client.Result) {
+	t.Requests++
+	t.Cost = overflow.Addp(t.Cost, result.Cost)
+	t.CostSaved = overflow.Addp(t.CostSaved, result.Saved)
+	t.Bytes += len(result.Value)
+	if result.Hit {
+		t.Hits++
+		t.BytesHit += len(result.Value)
+	}
+	t.updateRates()
+}
+
+/*merge folds another tally into this one*/
+func (t *tally) merge(other tally) {
+	t.Requests += other.Requests
+	t.Hits += other.Hits
+	t.Cost = overflow.Addp(t.Cost, other.Cost)
+	t.CostSaved = overflow.Addp(t.CostSaved, other.CostSaved)
+	t.Bytes += other.Bytes
+	t.BytesHit += other.BytesHit
+	t.updateRates()
+}
+
+/*since is what was tallied after an earlier snapshot of this tally*/
+func (t tally) since(earlier tally) tally {
+	delta := tally{
+		Requests:  t.Requests - earlier.Requests,
+		Hits:      t.Hits - earlier.Hits,
+		Cost:      t.Cost - earlier.Cost,
+		CostSaved: t.CostSaved - earlier.CostSaved,
+		Bytes:     t.Bytes - earlier.Bytes,
+		BytesHit:  t.BytesHit - earlier.BytesHit,
+	}
+	delta.updateRates()
+	return delta
+}
+
+func (t *tally) updateRates() {
+	t.HitRate = ratio(t.Hits, t.Requests)
+	t.ByteHitRate = ratio(t.BytesHit, t.Bytes)
+	t.CostHitRate = ratio(t.CostSaved, overflow.Addp(t.Cost, t.CostSaved))
+}
+
+/*fileReport is how one keyfile of the trace did on its own*/
+type fileReport struct {
+	File string `json:"file"`
+	tally
+}
+
+/*runReport is everything a client run measured, written with
+-report so results don't have to be scraped from the output.
+Cache type and size come from the server's stats.  Intervals
+are how each run of 10000 keys did on its own, ending at the
+same points as the KEY/CURRENT/HITRATE lines*/
+type runReport struct {
+	Label     string       `json:"label"`
+	Traces    []string     `json:"traces"`
+	CacheType string       `json:"cache_type"`
+	CacheSize int          `json:"cache_size"`
+	Intervals []tally      `json:"intervals"`
+	Files     []fileReport `json:"files"`
+	Classes   classTallies `json:"classes,omitempty"`
+	tally
+}
+
+/*classTallies breaks a run down by key class, when the client
+was given -classes*/
+type classTallies map[string]*tally
+
+func (ct classTallies) add(class string, result client.Result) {
+	counters, ok := ct[class]
+	if !ok {
+		counters = &tally{}
+		ct[class] = counters
+	}
+	counters.add(result)
+}
+
+func (ct classTallies) merge(other classTallies) {
+	for class, counters := range other {
+		if _, ok := ct[class]; !ok {
+			ct[class] = &tally{}
+		}
+		ct[class].merge(*counters)
+	}
+}
+
+func (ct classTallies) names() []string {
+	names := make([]string, 0, len(ct))
+	for name := range ct {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func printClasses(ct classTallies) {
+	for _, name := range ct.names() {
+		counters := ct[name]
+		fmt.Println("CLASS", name, "REQUESTS", counters.Requests, "HITS", counters.Hits,
+			"MISSES", counters.Requests-counters.Hits, "HIT RATE", counters.HitRate,
+			"COST", counters.Cost, "COST HIT RATE", counters.CostHitRate)
+	}
+}
+
+/*traceLabel names a run after its keyfiles when no -label is given*/
+func traceLabel(traces []string) string {
+	names := []string{}
+	for _, trace := range traces {
+		names = append(names, strings.TrimSuffix(filepath.Base(trace), filepath.Ext(trace)))
+	}
+	return strings.Join(names, "+")
+}
+
+/*parseSummary splits the stats response into its KEY:value pairs*/
+func parseSummary(summary string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(summary, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	return fields
+}
+
+func serverStats(c *client.Client) (map[string]string, error) {
+	summary, err := c.Stats(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return parseSummary(summary), nil
+}
+
+/*reportFilename defaults to client_report.<format> in the working directory*/
+func reportFilename(conf *clientConf) string {
+	if conf.reportFile != "" {
+		return conf.reportFile
+	}
+	return "./client_report." + conf.report
+}
+
+func writeReport(report *runReport, format string, filename string) error {
+	if format == "csv" {
+		return writeReportCSV(report, filename)
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, body, 0666)
+}
+
+var reportColumns = []string{
+	"scope", "name", "cache_type", "cache_size",
+	"requests", "hits", "cost", "cost_saved", "bytes", "bytes_hit",
+	"hit_rate", "byte_hit_rate", "cost_hit_rate",
+}
+
+func tallyColumns(t tally) []string {
+	return []string{
+		strconv.Itoa(t.Requests), strconv.Itoa(t.Hits), strconv.Itoa(t.Cost),
+		strconv.Itoa(t.CostSaved), strconv.Itoa(t.Bytes), strconv.Itoa(t.BytesHit),
+		strconv.FormatFloat(t.HitRate, 'f', 6, 64),
+		strconv.FormatFloat(t.ByteHitRate, 'f', 6, 64),
+		strconv.FormatFloat(t.CostHitRate, 'f', 6, 64),
+	}
+}
+
+/*writeReportCSV flattens the report into one table: a "run" row
+for the totals, then a "file" row per keyfile, a "class" row per
+key class and an "interval" row per sample, telling them apart
+by the scope column*/
+func writeReportCSV(report *runReport, filename string) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write(reportColumns)
+	writer.Write(append([]string{"run", report.Label, report.CacheType, strconv.Itoa(report.CacheSize)}, tallyColumns(report.tally)...))
+	for _, fr := range report.Files {
+		writer.Write(append([]string{"file", fr.File, "", ""}, tallyColumns(fr.tally)...))
+	}
+	for _, name := range report.Classes.names() {
+		writer.Write(append([]string{"class", name, "", ""}, tallyColumns(*report.Classes[name])...))
+	}
+	for _, interval := range report.Intervals {
+		writer.Write(append([]string{"interval", "", "", ""}, tallyColumns(interval)...))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+/*readReport loads a report written by either format, going by
+the file extension.  Only what compare needs is read back from CSV*/
+func readReport(filename string) (*runReport, error) {
+	if !strings.HasSuffix(filename, ".csv") {
+		body, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		report := &runReport{}
+		err = json.Unmarshal(body, report)
+		return report, err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	report := &runReport{}
+	if len(rows) == 0 {
+		return report, nil
+	}
+	for _, row := range rows[1:] {
+		if row[0] == "run" {
+			report.Label = row[1]
+			report.CacheType = row[2]
+			report.CacheSize, _ = strconv.Atoi(row[3])
+			report.Requests, _ = strconv.Atoi(row[4])
+			report.Hits, _ = strconv.Atoi(row[5])
+			report.Cost, _ = strconv.Atoi(row[6])
+			report.CostSaved, _ = strconv.Atoi(row[7])
+			report.Bytes, _ = strconv.Atoi(row[8])
+			report.BytesHit, _ = strconv.Atoi(row[9])
+			report.HitRate, _ = strconv.ParseFloat(row[10], 64)
+			report.ByteHitRate, _ = strconv.ParseFloat(row[11], 64)
+			report.CostHitRate, _ = strconv.ParseFloat(row[12], 64)
+		} else if row[0] == "file" {
+			report.Traces = append(report.Traces, row[1])
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/client"
+)
+
+func TestTallySince(t *testing.T) {
+	var running tally
+	running.add(client.Result{Key: "key1", Value: "val1", Cost: 10})
+	running.add(client.Result{Key: "key1", Value: "val1", Saved: 10, Hit: true})
+	snapshot := running
+	running.add(client.Result{Key: "key2", Value: "val2", Cost: 20})
+	running.add(client.Result{Key: "key3", Value: "val3", Cost: 30})
+	running.add(client.Result{Key: "key2", Value: "val2", Saved: 20, Hit: true})
+
+	interval := running.since(snapshot)
+	want := tally{Requests: 3, Hits: 1, Cost: 50, CostSaved: 20, Bytes: 12, BytesHit: 4,
+		HitRate: 1.0 / 3, ByteHitRate: 1.0 / 3, CostHitRate: 20.0 / 70}
+	if interval != want {
+		t.Errorf("got %+v, want %+v", interval, want)
+	}
+	if first := snapshot.since(tally{}); first != snapshot {
+		t.Errorf("the first interval should be everything so far, got %+v, want %+v", first, snapshot)
+	}
+	if none := running.since(running); none != (tally{}) {
+		t.Errorf("nothing happened since, got %+v", none)
+	}
+	if running.Requests != 5 || running.HitRate != 0.4 {
+		t.Errorf("since changed the running tally: %+v", running)
+	}
+}
+
+func testReport() *runReport {
+	report := &runReport{
+		Label:     "lru",
+		Traces:    []string{"a.csv", "b.csv"},
+		CacheType: "LRU",
+		CacheSize: 100,
+		Classes:   classTallies{},
+	}
+	files := []fileReport{{File: "a.csv"}, {File: "b.csv"}}
+	results := []client.Result{
+		{Key: "key1", Value: "val1", Cost: 10},
+		{Key: "key1", Value: "val1", Saved: 10, Hit: true},
+		{Key: "key2", Value: "val2", Cost: 20},
+	}
+	for i, result := range results {
+		report.add(result)
+		files[i/2].add(result)
+		report.Classes.add("cost_1e1", result)
+		report.Intervals = append(report.Intervals, report.tally.since(tally{}))
+	}
+	report.Files = files
+	return report
+}
+
+func TestReportJSON(t *testing.T) {
+	report := testReport()
+	filename := filepath.Join(t.TempDir(), "report.json")
+	if err := writeReport(report, "json", filename); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readReport(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, report) {
+		t.Errorf("got %+v, want %+v", read, report)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	report := testReport()
+	filename := filepath.Join(t.TempDir(), "report.csv")
+	if err := writeReport(report, "csv", filename); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		reportColumns,
+		{"run", "lru", "LRU", "100", "3", "1", "30", "10", "12", "4", "0.333333", "0.333333", "0.250000"},
+		{"file", "a.csv", "", "", "2", "1", "10", "10", "8", "4", "0.500000", "0.500000", "0.500000"},
+		{"file", "b.csv", "", "", "1", "0", "20", "0", "4", "0", "0.000000", "0.000000", "0.000000"},
+		{"class", "cost_1e1", "", "", "3", "1", "30", "10", "12", "4", "0.333333", "0.333333", "0.250000"},
+		{"interval", "", "", "", "1", "0", "10", "0", "4", "0", "0.000000", "0.000000", "0.000000"},
+		{"interval", "", "", "", "2", "1", "10", "10", "8", "4", "0.500000", "0.500000", "0.500000"},
+		{"interval", "", "", "", "3", "1", "30", "10", "12", "4", "0.333333", "0.333333", "0.250000"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got\n%v\nwant\n%v", rows, want)
+	}
+
+	// compare only needs the run row and the file names back
+	read, err := readReport(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Label != "lru" || read.CacheType != "LRU" || read.CacheSize != 100 ||
+		!reflect.DeepEqual(read.Traces, report.Traces) {
+		t.Errorf("got %+v", read)
+	}
+	if read.tally.Requests != 3 || read.Cost != 30 || read.CostSaved != 10 || read.CostHitRate != 0.25 {
+		t.Errorf("got totals %+v, want %+v", read.tally, report.tally)
+	}
+}
+
+func TestTraceLabel(t *testing.T) {
+	got := traceLabel([]string{"./data/client/generated_lru_keys.csv", "trace.bin.zst", "stdin"})
+	if got != "generated_lru_keys+trace.bin+stdin" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/evizitei/lcr-cache/pkg/client"
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+/*verifier checks every value the server returns against the
+dataset it was started with, so a cache that hands back the
+wrong entry shows up as a mismatch rather than a hit.  Load
+runs check from several workers at once, hence the lock*/
+type verifier struct {
+	lock       sync.Mutex
+	records    map[string]dataset.Record
+	policy     string
+	checked    int
+	mismatches int
+}
+
+func newVerifier(conf *clientConf, c *client.Client) *verifier {
+	records, err := dataset.Read(conf.dataFile)
+	if err != nil {
+		fmt.Println("ERROR reading dataset: ", err)
+		os.Exit(-1)
+	}
+	stats, err := serverStats(c)
+	if err != nil {
+		fmt.Println("ERROR fetching server stats: ", err)
+		os.Exit(-1)
+	}
+	return &verifier{records: records, policy: stats["CACHE"]}
+}
+
+/*check compares one result with the dataset, index being the
+request's position in the trace*/
+func (v *verifier) check(index int, result client.Result) {
+	record, known := v.records[result.Key]
+	problem := ""
+	if result.Err == client.ErrNotFound {
+		if known {
+			problem = "server has no entry, dataset has " + record.Value
+		}
+	} else if result.Err != nil {
+		return
+	} else if !known {
+		problem = "dataset has no entry, server returned " + result.Value
+	} else if result.Value != record.Value {
+		problem = "expected " + record.Value + ", got " + result.Value
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.checked++
+	if problem == "" {
+		return
+	}
+	v.mismatches++
+	fmt.Println("MISMATCH request", index, "key", result.Key, "policy", v.policy, "hit", result.Hit, problem)
+}
+
+/*finish reports the totals, exiting non-zero if anything was wrong*/
+func (v *verifier) finish() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	fmt.Println("VERIFIED:", v.checked, "MISMATCHES:", v.mismatches)
+	if v.mismatches > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/client"
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+func testVerifier() *verifier {
+	return &verifier{policy: "LRU", records: map[string]dataset.Record{
+		"key1": {Key: "key1", Value: "val1", Cost: 10},
+		"key2": {Key: "key2", Value: "val2", Cost: 20},
+	}}
+}
+
+func TestVerifierCounts(t *testing.T) {
+	cases := []struct {
+		name     string
+		result   client.Result
+		checked  int
+		mismatch int
+	}{
+		{"right value", client.Result{Key: "key1", Value: "val1"}, 1, 0},
+		{"right value on a hit", client.Result{Key: "key2", Value: "val2", Hit: true}, 1, 0},
+		{"wrong value", client.Result{Key: "key1", Value: "val2", Hit: true}, 1, 1},
+		{"empty value", client.Result{Key: "key1"}, 1, 1},
+		{"missing on the server", client.Result{Key: "key1", Err: client.ErrNotFound}, 1, 1},
+		{"missing everywhere", client.Result{Key: "nope", Err: client.ErrNotFound}, 1, 0},
+		{"missing from the dataset", client.Result{Key: "nope", Value: "val1"}, 1, 1},
+		{"failed request", client.Result{Key: "key1", Err: errors.New("Bad request")}, 0, 0},
+	}
+	for _, c := range cases {
+		v := testVerifier()
+		v.check(1, c.result)
+		if v.checked != c.checked || v.mismatches != c.mismatch {
+			t.Errorf("%s: checked %d with %d mismatches, want %d with %d", c.name, v.checked, v.mismatches, c.checked, c.mismatch)
+		}
+	}
+}
+
+func TestVerifierConcurrent(t *testing.T) {
+	v := testVerifier()
+	var workers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		workers.Add(1)
+		go func(worker int) {
+			defer workers.Done()
+			for j := 0; j < 100; j++ {
+				result := client.Result{Key: "key1", Value: "val1"}
+				if j%10 == 0 {
+					result.Value = "stale"
+				}
+				v.check(worker*100+j, result)
+			}
+		}(i)
+	}
+	workers.Wait()
+	if v.checked != 800 || v.mismatches != 80 {
+		t.Errorf("checked %d with %d mismatches, want 800 with 80", v.checked, v.mismatches)
+	}
+	// no mismatches, so this reports without exiting
+	clean := testVerifier()
+	clean.check(1, client.Result{Key: "key2", Value: "val2"})
+	clean.finish()
+}
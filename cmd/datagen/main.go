@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/datagen"
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+type datagenConf struct {
+	keys        int
+	output      string
+	seed        int64
+	cost        string
+	correlation float64
+	popularity  string
+	trace       string
+	format      string
+	valueSize   string
+	valueMax    int
+}
+
+func parseArgs() *datagenConf {
+	distributions := strings.Join(datagen.DistributionNames(), ", ")
+	keys := flag.Int("keys", 10000, "number of keys, named key0 to key<keys-1>")
+	output := flag.String("output", "", "file to write the dataset to (stdout when empty)")
+	seed := flag.Int64("seed", 1, "random seed, the same seed and parameters always give the same dataset")
+	cost := flag.String("cost", "lognormal", "cost distribution, like pareto:xm=1000,alpha=1.2 ("+distributions+")")
+	correlation := flag.Float64("cost_correlation", 0, "how costs follow popularity, from -1 (popular keys cheapest) to 1 (popular keys most expensive)")
+	popularity := flag.String("popularity", "", "trace to rank popularity by (default key0 is the most popular, like tracegen's ZIPF)")
+	traceFile := flag.String("trace", "", "trace file(s) to take the keys from instead of key0 to key<keys-1>, comma separated")
+	traceFormat := flag.String("trace_format", "keys", "format of the -trace files, one of ("+strings.Join(trace.Formats, ", ")+")")
+	valueSize := flag.String("value_size", "", "value size distribution in bytes (default the -trace's sizes when it has them, otherwise val<n> like test_set_1.csv)")
+	valueMax := flag.Int("value_max", 1024*1024, "largest value size to generate")
+	flag.Parse()
+	return &datagenConf{
+		keys:        *keys,
+		output:      *output,
+		seed:        *seed,
+		cost:        *cost,
+		correlation: *correlation,
+		popularity:  *popularity,
+		trace:       *traceFile,
+		format:      *traceFormat,
+		valueSize:   *valueSize,
+		valueMax:    *valueMax,
+	}
+}
+
+/*popularityRanks ranks the keys by how often the trace asks for
+them, or simply in key order when there's no trace*/
+func popularityRanks(conf *datagenConf) ([]int, error) {
+	if conf.popularity == "" {
+		ranked := make([]int, conf.keys)
+		for i := range ranked {
+			ranked[i] = i
+		}
+		return ranked, nil
+	}
+	counts := map[int]int{}
+	err := trace.Each([]string{conf.popularity}, "keys", func(request trace.Request) error {
+		n, err := strconv.Atoi(strings.TrimPrefix(request.Key, "key"))
+		if err == nil && n >= 0 && n < conf.keys {
+			counts[n]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return datagen.PopularityFromCounts(counts, conf.keys), nil
+}
+
+/*traceKeys lists the distinct keys of a trace, most requested
+first, along with the largest size the trace gives each one*/
+func traceKeys(conf *datagenConf) ([]string, map[string]int, error) {
+	keys := []string{}
+	counts := map[string]int{}
+	sizes := map[string]int{}
+	err := trace.Each(strings.Split(conf.trace, ","), conf.format, func(request trace.Request) error {
+		if counts[request.Key] == 0 {
+			keys = append(keys, request.Key)
+		}
+		counts[request.Key]++
+		if request.Size > sizes[request.Key] {
+			sizes[request.Key] = request.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+	return keys, sizes, nil
+}
+
+func generate(conf *datagenConf) ([]dataset.Record, error) {
+	rng := rand.New(rand.NewSource(conf.seed))
+	costDist, err := datagen.ParseDistribution(conf.cost, rng)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	var traceSizes map[string]int
+	if conf.trace != "" {
+		if conf.popularity != "" {
+			return nil, fmt.Errorf("-popularity is for key<n> datasets, a -trace ranks its own keys")
+		}
+		keys, traceSizes, err = traceKeys(conf)
+		if err != nil {
+			return nil, err
+		}
+		// keys come most popular first, so key order is popularity order
+		conf.keys = len(keys)
+	}
+	costs := datagen.Costs(costDist, conf.keys)
+	if conf.correlation != 0 {
+		if conf.correlation < -1 || conf.correlation > 1 {
+			return nil, fmt.Errorf("cost_correlation must be between -1 and 1")
+		}
+		ranks, err := popularityRanks(conf)
+		if err != nil {
+			return nil, err
+		}
+		costs = datagen.Correlate(costs, ranks, conf.correlation, rng)
+	}
+	var sizeDist datagen.Distribution
+	if conf.valueSize != "" {
+		sizeDist, err = datagen.ParseDistribution(conf.valueSize, rng)
+		if err != nil {
+			return nil, err
+		}
+	}
+	records := make([]dataset.Record, conf.keys)
+	for i := range records {
+		key := "key" + strconv.Itoa(i)
+		if keys != nil {
+			key = keys[i]
+		}
+		value := datagen.DefaultValue(i)
+		size := 0
+		if sizeDist != nil {
+			// capped as a float, a heavy tail can overflow an int
+			size = int(math.Max(1, math.Round(math.Min(sizeDist.Sample(), float64(conf.valueMax)))))
+		} else if traceSizes[key] > 0 {
+			size = traceSizes[key]
+		}
+		if size > 0 {
+			if size > conf.valueMax {
+				size = conf.valueMax
+			}
+			value = datagen.Value(size, rng)
+		}
+		records[i] = dataset.Record{Key: key, Value: value, Cost: costs[i]}
+	}
+	return records, nil
+}
+
+func main() {
+	conf := parseArgs()
+	records, err := generate(conf)
+	if err != nil {
+		fmt.Println("ERROR generating dataset: ", err)
+		os.Exit(-1)
+	}
+	out := os.Stdout
+	if conf.output != "" {
+		file, err := os.OpenFile(conf.output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+		if err != nil {
+			fmt.Println("ERROR opening output: ", err)
+			os.Exit(-1)
+		}
+		defer file.Close()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	err = dataset.Write(writer, records)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		fmt.Println("ERROR writing dataset: ", err)
+		os.Exit(-1)
+	}
+}
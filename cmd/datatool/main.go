@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/datagen"
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+/*transformList collects -transform flags in the order given*/
+type transformList []string
+
+func (t *transformList) String() string {
+	return strings.Join(*t, " ")
+}
+
+func (t *transformList) Set(spec string) error {
+	*t = append(*t, spec)
+	return nil
+}
+
+type datatoolConf struct {
+	input      string
+	output     string
+	seed       int64
+	transforms transformList
+}
+
+func parseArgs() *datatoolConf {
+	conf := &datatoolConf{}
+	flag.StringVar(&conf.input, "input", "./data/test_set_1.csv", "dataset to transform")
+	flag.StringVar(&conf.output, "output", "", "file to write the new dataset to (stdout when empty)")
+	flag.Int64Var(&conf.seed, "seed", 1, "random seed for shuffle and noise")
+	flag.Var(&conf.transforms, "transform", "transform to apply, like rescale:direction=down,factor=50; repeat to apply several in order ("+
+		strings.Join(datagen.TransformNames(), ", ")+")")
+	flag.Parse()
+	return conf
+}
+
+func transform(conf *datatoolConf) ([]dataset.Record, error) {
+	if len(conf.transforms) == 0 {
+		return nil, fmt.Errorf("no -transform given")
+	}
+	rng := rand.New(rand.NewSource(conf.seed))
+	transforms := []datagen.Transform{}
+	for _, spec := range conf.transforms {
+		t, err := datagen.ParseTransform(spec, rng)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	records, err := dataset.ReadOrdered(conf.input)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transforms {
+		t(records)
+	}
+	return records, nil
+}
+
+func main() {
+	conf := parseArgs()
+	records, err := transform(conf)
+	if err != nil {
+		fmt.Println("ERROR transforming dataset: ", err)
+		os.Exit(-1)
+	}
+	out := os.Stdout
+	if conf.output != "" {
+		file, err := os.OpenFile(conf.output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+		if err != nil {
+			fmt.Println("ERROR opening output: ", err)
+			os.Exit(-1)
+		}
+		defer file.Close()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	err = dataset.Write(writer, records)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		fmt.Println("ERROR writing dataset: ", err)
+		os.Exit(-1)
+	}
+}
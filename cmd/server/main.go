@@ -2,8 +2,13 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"time"
 
+	"github.com/evizitei/lcr-cache/pkg/address"
 	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/classes"
 )
 
 func parseArgs() *cache.ServerConf {
@@ -12,13 +17,59 @@ func parseArgs() *cache.ServerConf {
 	cacheType := flag.String("cache_type", "FIFO", "One of (NONE, FIFO, LRU, LFU, LCR, LECAR, LECARAC)")
 	cacheSize := flag.Int("cache_size", 1000, "number of entries the cache is able to hold")
 	verbose := flag.Bool("verbose", false, "wheter you want a lot of output")
+	host := flag.String("host", "", "interface to listen on (all interfaces by default)")
+	port := flag.Int("port", 1234, "port to listen on")
+	socket := flag.String("socket", "", "unix domain socket path to listen on instead of host/port")
+	listen := flag.String("listen", "", "address to listen on, host:port or unix:/path/to.sock, instead of -host/-port/-socket")
+	drainTimeout := flag.Duration("drain_timeout", 5*time.Second, "how long to wait for in-flight connections when shutting down")
+	reportFile := flag.String("report_file", "", "optional file to write the final report to as JSON")
+	adminPort := flag.Int("admin_port", 0, "port to serve prometheus /metrics on (disabled when 0)")
+	sampleEvery := flag.Int("sample_every", 1000, "requests between time series samples")
+	sampleFile := flag.String("sample_file", "", "optional CSV (or .json) file to write a time series of weights, hit rate and cost to")
+	httpPort := flag.Int("http_port", 0, "port to serve the HTTP/JSON front end on (disabled when 0)")
+	memcachedPort := flag.Int("memcached_port", 0, "port to speak the memcached text protocol on (disabled when 0)")
+	respPort := flag.Int("resp_port", 0, "port to speak the redis RESP protocol on (disabled when 0)")
+	binaryPort := flag.Int("binary_port", 0, "port to speak the length-prefixed binary protocol on (disabled when 0)")
+	recordFile := flag.String("record_file", "", "optional file to record every request to, for replaying with -trace_format recording")
+	keyClasses := flag.String("classes", "", "break stats down by key class: a key,class CSV and/or cost, comma separated")
 	flag.Parse()
+	if *listen != "" {
+		var err error
+		*host, *port, *socket, err = address.Split(*listen)
+		if err != nil {
+			fmt.Println("ERROR parsing -listen: ", err)
+			os.Exit(-1)
+		}
+	}
+	var classifier *classes.Classifier
+	if *keyClasses != "" {
+		var err error
+		classifier, err = classes.New(*keyClasses, nil, "")
+		if err != nil {
+			fmt.Println("ERROR reading key classes: ", err)
+			os.Exit(-1)
+		}
+	}
 	return &cache.ServerConf{
-		LogFile:   logFile,
-		DataFile:  dataFile,
-		CacheType: cacheType,
-		CacheSize: *cacheSize,
-		Verbose:   *verbose,
+		LogFile:       logFile,
+		DataFile:      dataFile,
+		CacheType:     cacheType,
+		CacheSize:     *cacheSize,
+		Verbose:       *verbose,
+		Host:          *host,
+		Port:          *port,
+		Socket:        *socket,
+		DrainTimeout:  *drainTimeout,
+		ReportFile:    *reportFile,
+		AdminPort:     *adminPort,
+		SampleEvery:   *sampleEvery,
+		SampleFile:    *sampleFile,
+		HTTPPort:      *httpPort,
+		MemcachedPort: *memcachedPort,
+		RespPort:      *respPort,
+		BinaryPort:    *binaryPort,
+		Classifier:    classifier,
+		RecordFile:    *recordFile,
 	}
 }
 
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/classes"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+func parseArgs() (*cache.ServerConf, []string, string) {
+	logFile := flag.String("logfile", "./log/simulator.log", "file to write log outputs to as the simulation runs")
+	dataFile := flag.String("data_file", "./data/test_set_1.csv", "file to read working set from")
+	cacheType := flag.String("cache_type", "FIFO", "One of (NONE, FIFO, LRU, LFU, LCR, LECAR, CALECAR)")
+	cacheSize := flag.Int("cache_size", 1000, "number of entries the cache is able to hold")
+	keyFile := flag.String("keyfile", "./data/client/traffic_set_baseline.csv", "file(s) with series of keys to fetch, comma separated")
+	traceFormat := flag.String("trace_format", "keys", "format of the keyfiles, one of ("+strings.Join(trace.Formats, ", ")+")")
+	verbose := flag.Bool("verbose", false, "wheter you want a lot of output")
+	reportFile := flag.String("report_file", "", "optional file to write the final report to as JSON")
+	sampleEvery := flag.Int("sample_every", 1000, "requests between time series samples")
+	sampleFile := flag.String("sample_file", "", "optional CSV (or .json) file to write a time series of weights, hit rate and cost to")
+	keyClasses := flag.String("classes", "", "break results down by key class: a key,class CSV, cost and/or frequency, comma separated")
+	flag.Parse()
+	keyFiles := strings.Split(*keyFile, ",")
+	var classifier *classes.Classifier
+	if *keyClasses != "" {
+		var err error
+		classifier, err = classes.New(*keyClasses, keyFiles, *traceFormat)
+		if err != nil {
+			fmt.Println("ERROR reading key classes: ", err)
+			os.Exit(-1)
+		}
+	}
+	return &cache.ServerConf{
+		LogFile:     logFile,
+		DataFile:    dataFile,
+		CacheType:   cacheType,
+		CacheSize:   *cacheSize,
+		Verbose:     *verbose,
+		ReportFile:  *reportFile,
+		SampleEvery: *sampleEvery,
+		SampleFile:  *sampleFile,
+		Classifier:  classifier,
+	}, keyFiles, *traceFormat
+}
+
+func main() {
+	conf, keyFiles, traceFormat := parseArgs()
+	server := cache.NewServer(conf)
+	err := server.Simulate(keyFiles, traceFormat)
+	if err != nil {
+		fmt.Println("ERROR simulating traffic: ", err)
+		os.Exit(-1)
+	}
+	report := server.Report()
+	fmt.Println("TRAFFIC COST: ", report.CostServed)
+	fmt.Println("COST SAVED: ", report.CostSaved)
+	fmt.Println("HIT RATE:", report.HitRate())
+	fmt.Println("BYTE HIT RATE:", report.ByteHitRate())
+	fmt.Println("COST HIT RATE:", report.CostHitRate())
+	for _, name := range report.ClassNames() {
+		class := report.Classes[name]
+		fmt.Println("CLASS", name, "REQUESTS", class.Requests, "HITS", class.Hits, "MISSES", class.Misses,
+			"HIT RATE", class.HitRate(), "COST", class.CostServed, "COST HIT RATE", class.CostHitRate())
+	}
+}
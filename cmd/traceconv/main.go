@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+type traceconvConf struct {
+	input        string
+	inputFormat  string
+	output       string
+	outputFormat string
+	timestamps   bool
+}
+
+func parseArgs() *traceconvConf {
+	input := flag.String("input", "-", "trace to convert, gzip and zstd are recognized (- for stdin)")
+	inputFormat := flag.String("input_format", "keys", "format of the input, one of ("+strings.Join(trace.Formats, ", ")+")")
+	output := flag.String("output", "-", "file to write, compressed if it ends in .gz or .zst (- for stdout)")
+	outputFormat := flag.String("output_format", "binary", "format to write, one of ("+strings.Join(trace.WriteFormats, ", ")+")")
+	timestamps := flag.Bool("timestamps", false, "write each request's time as a second column of seconds (keys output only)")
+	flag.Parse()
+	return &traceconvConf{
+		input:        *input,
+		inputFormat:  *inputFormat,
+		output:       *output,
+		outputFormat: *outputFormat,
+		timestamps:   *timestamps,
+	}
+}
+
+/*convert streams the input into the output a request at a time,
+returning how many requests it copied*/
+func convert(conf *traceconvConf) (int, error) {
+	in, err := trace.Open(conf.input, conf.inputFormat)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := trace.Create(conf.output)
+	if err != nil {
+		return 0, err
+	}
+	writer, err := trace.NewWriter(out, conf.outputFormat, conf.timestamps)
+	if err != nil {
+		out.Close()
+		return 0, err
+	}
+	count := 0
+	for {
+		request, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && request.IsEvent() {
+			// neither output format can hold a recording's flushes and resets
+			continue
+		}
+		if err == nil {
+			err = writer.Write(request)
+		}
+		if err != nil {
+			out.Close()
+			return count, err
+		}
+		count++
+	}
+	err = writer.Flush()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return count, err
+}
+
+func main() {
+	conf := parseArgs()
+	count, err := convert(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR converting trace: ", err)
+		os.Exit(-1)
+	}
+	// the converted trace may be on stdout, so this goes to stderr
+	fmt.Fprintln(os.Stderr, "CONVERTED REQUESTS:", count)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+	"github.com/evizitei/lcr-cache/pkg/tracegen"
+)
+
+type tracegenConf struct {
+	generator string
+	output    string
+	count     int
+	seed      int64
+	keys      []string
+	workload  *tracegen.Workload
+	phaseFile string
+	phases    *phaseLog
+	params    tracegen.Params
+}
+
+/*phaseLog records the request index where each phase of a
+workload starts, to line up with plots of the expert weights*/
+type phaseLog struct {
+	file    *os.File
+	writer  *csv.Writer
+	request int
+}
+
+func newPhaseLog(filename string) (*phaseLog, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	log := &phaseLog{file: file, writer: csv.NewWriter(file)}
+	log.writer.Write([]string{"request", "phase"})
+	return log, nil
+}
+
+func (pl *phaseLog) enter(name string) {
+	pl.writer.Write([]string{strconv.Itoa(pl.request), name})
+}
+
+func (pl *phaseLog) close() error {
+	pl.writer.Flush()
+	err := pl.writer.Error()
+	pl.file.Close()
+	return err
+}
+
+func parseArgs() *tracegenConf {
+	generator := flag.String("generator", "LRU", "One of ("+strings.Join(tracegen.Generators, ", ")+")")
+	output := flag.String("output", "", "file to write the trace to, compressed if it ends in .gz or .zst (stdout when empty)")
+	count := flag.Int("count", 100000, "number of keys to generate")
+	seed := flag.Int64("seed", 1, "random seed, the same seed and parameters always give the same trace")
+	maxKey := flag.Int("max_key", 10000, "keys are numbered 0 to max_key-1")
+	sigma := flag.Float64("sigma", 0, "LRU: standard deviation of the random walk (default 8)")
+	walkMin := flag.Int("walk_min", 0, "LRU: lowest the walk's center can go (default 75)")
+	walkMax := flag.Int("walk_max", 0, "LRU: highest the walk's center can go (default max_key-25)")
+	freqSetSize := flag.Int("freq_set_size", 0, "LFU/LCR: size of the frequent set (default 200 for LFU, 250 for LCR)")
+	freqRange := flag.Int("freq_range", 0, "LFU/LCR: frequent keys are drawn from 0 to freq_range-1 (default max_key for LFU, 1000 for LCR)")
+	costSetSize := flag.Int("cost_set_size", 0, "LCR: size of the high cost set (default 250)")
+	costFraction := flag.Float64("cost_fraction", 0, "LCR: the cost set is drawn from this top fraction of keys (default 0.1)")
+	freqProb := flag.Float64("freq_prob", 0, "LFU/LCR: chance of a request to the frequent set (default 0.99 for LFU, 0.495 for LCR)")
+	costProb := flag.Float64("cost_prob", 0, "LCR: chance of a request to the cost set (default 0.495)")
+	scanLength := flag.Int("scan_length", 0, "length of scan runs (default 200) or _SCAN phases (default 250)")
+	alpha := flag.Float64("alpha", 0.99, "ZIPF/SCRAMBLED_ZIPF/LATEST: skew of the zipf curve, 0 being uniform")
+	hotFraction := flag.Float64("hot_fraction", 0.2, "HOTSPOT: fraction of the keys that are hot")
+	hotProb := flag.Float64("hot_prob", 0.8, "HOTSPOT: fraction of requests that go to the hot keys")
+	insertProb := flag.Float64("insert_prob", 0.05, "LATEST: chance each request inserts a new key")
+	dataFile := flag.String("data_file", "", "draw keys from this dataset, in file order, instead of key0 to key<max_key-1>")
+	workloadFile := flag.String("workload", "", "JSON workload spec of phases to compile into a trace, instead of -generator")
+	phaseFile := flag.String("phase_file", "", "with -workload, a CSV of the request index where each named phase starts")
+	flag.Parse()
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var workload *tracegen.Workload
+	if *workloadFile != "" {
+		var err error
+		workload, err = tracegen.ReadWorkload(*workloadFile)
+		if err != nil {
+			fmt.Println("ERROR reading workload: ", err)
+			os.Exit(-1)
+		}
+		// the spec decides what it can unless a flag says otherwise
+		if !explicit["count"] && workload.Length() > 0 {
+			*count = workload.Length()
+		}
+		if !explicit["seed"] && workload.Seed != 0 {
+			*seed = workload.Seed
+		}
+		if explicit["max_key"] {
+			// key ranges are checked against it when the spec compiles
+			workload.MaxKey = *maxKey
+		} else {
+			*maxKey = workload.MaxKey
+		}
+	}
+	var keys []string
+	if *dataFile != "" {
+		records, err := dataset.ReadOrdered(*dataFile)
+		if err != nil {
+			fmt.Println("ERROR reading dataset: ", err)
+			os.Exit(-1)
+		}
+		for _, record := range records {
+			keys = append(keys, record.Key)
+		}
+		if workload != nil && workload.MaxKey > len(keys) {
+			fmt.Println("ERROR: the workload's max_key is larger than the dataset")
+			os.Exit(-1)
+		}
+		*maxKey = len(keys)
+	}
+	params := tracegen.Params{
+		MaxKey:       *maxKey,
+		Sigma:        *sigma,
+		WalkMin:      *walkMin,
+		WalkMax:      *walkMax,
+		FreqSetSize:  *freqSetSize,
+		FreqRange:    *freqRange,
+		CostSetSize:  *costSetSize,
+		CostFraction: *costFraction,
+		FreqProb:     *freqProb,
+		CostProb:     *costProb,
+		ScanLength:   *scanLength,
+	}
+	// these can be set to 0, so they're only passed on when given
+	if explicit["alpha"] {
+		params.Alpha = alpha
+	}
+	if explicit["hot_fraction"] {
+		params.HotFraction = hotFraction
+	}
+	if explicit["hot_prob"] {
+		params.HotProb = hotProb
+	}
+	if explicit["insert_prob"] {
+		params.InsertProb = insertProb
+	}
+	return &tracegenConf{
+		generator: *generator,
+		output:    *output,
+		count:     *count,
+		seed:      *seed,
+		keys:      keys,
+		workload:  workload,
+		phaseFile: *phaseFile,
+		params:    params,
+	}
+}
+
+/*writeTrace streams keys straight to the output, so the
+length of a trace is only limited by disk*/
+func writeTrace(conf *tracegenConf, generator tracegen.Generator) error {
+	out, err := trace.Create(conf.output)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriterSize(out, 64*1024)
+	line := []byte{}
+	for i := 0; i < conf.count; i++ {
+		if conf.phases != nil {
+			conf.phases.request = i
+		}
+		if conf.keys != nil {
+			line = append(append(line[:0], conf.keys[generator.Next()]...), '\n')
+		} else {
+			line = append(line[:0], "key"...)
+			line = strconv.AppendInt(line, int64(generator.Next()), 10)
+			line = append(line, '\n')
+		}
+		_, err := writer.Write(line)
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	err = writer.Flush()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*buildGenerator compiles the workload if there is one, noting
+where each phase starts in the phase file, otherwise it's the
+single generator named by -generator*/
+func buildGenerator(conf *tracegenConf, rng *rand.Rand) (tracegen.Generator, error) {
+	if conf.workload == nil {
+		return tracegen.New(conf.generator, conf.params, rng)
+	}
+	if conf.phaseFile == "" {
+		return conf.workload.Compile(rng, nil)
+	}
+	phases, err := newPhaseLog(conf.phaseFile)
+	if err != nil {
+		return nil, err
+	}
+	conf.phases = phases
+	return conf.workload.Compile(rng, phases.enter)
+}
+
+func main() {
+	conf := parseArgs()
+	rng := rand.New(rand.NewSource(conf.seed))
+	generator, err := buildGenerator(conf, rng)
+	if err != nil {
+		fmt.Println("ERROR building generator: ", err)
+		os.Exit(-1)
+	}
+	err = writeTrace(conf, generator)
+	if err == nil && conf.phases != nil {
+		err = conf.phases.close()
+	}
+	if err != nil {
+		fmt.Println("ERROR writing trace: ", err)
+		os.Exit(-1)
+	}
+}
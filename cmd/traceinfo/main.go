@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+	"github.com/evizitei/lcr-cache/pkg/traceinfo"
+)
+
+type traceinfoConf struct {
+	keyfile  string
+	format   string
+	dataFile string
+	window   int
+	sizes    []int
+}
+
+func parseArgs() *traceinfoConf {
+	keyFile := flag.String("keyfile", "./data/client/traffic_set_baseline.csv", "file(s) with series of keys to analyze, comma separated")
+	traceFormat := flag.String("trace_format", "keys", "format of the keyfiles, one of ("+strings.Join(trace.Formats, ", ")+")")
+	dataFile := flag.String("data_file", "./data/test_set_1.csv", "dataset to look up the cost of each key in (skipped when empty)")
+	window := flag.Int("window", 10000, "requests per window when counting the working set")
+	sizes := flag.String("sizes", "", "cache sizes for the LRU miss ratio curve, comma separated (default powers of two up to the number of keys)")
+	flag.Parse()
+	conf := &traceinfoConf{keyfile: *keyFile, format: *traceFormat, dataFile: *dataFile, window: *window}
+	if *sizes != "" {
+		for _, size := range strings.Split(*sizes, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil || n < 0 {
+				fmt.Println("ERROR bad cache size: ", size)
+				os.Exit(-1)
+			}
+			conf.sizes = append(conf.sizes, n)
+		}
+	}
+	return conf
+}
+
+func analyze(conf *traceinfoConf) (*traceinfo.Analyzer, error) {
+	analyzer := traceinfo.NewAnalyzer(conf.window)
+	err := trace.Each(strings.Split(conf.keyfile, ","), conf.format, func(request trace.Request) error {
+		analyzer.Add(request.Key)
+		return nil
+	})
+	return analyzer, err
+}
+
+func printCosts(label string, summary traceinfo.CostSummary) {
+	fmt.Printf("%s: count=%d mean=%.1f min=%d p50=%d p90=%d p99=%d max=%d\n", label,
+		summary.Count, summary.Mean, summary.Min, summary.P50, summary.P90, summary.P99, summary.Max)
+}
+
+func main() {
+	conf := parseArgs()
+	analyzer, err := analyze(conf)
+	if err != nil {
+		fmt.Println("ERROR reading keyfile: ", err)
+		os.Exit(-1)
+	}
+	fmt.Println("REQUESTS:", analyzer.Requests())
+	fmt.Println("UNIQUE KEYS:", analyzer.Unique())
+
+	skew := analyzer.FitSkew()
+	fmt.Printf("ZIPF ALPHA: %.3f (R2 %.3f)\n", skew.Alpha, skew.R2)
+	for i, fraction := range traceinfo.TopFractions {
+		fmt.Printf("TOP %g%% OF KEYS: %.3f of requests\n", fraction*100, skew.TopShares[i])
+	}
+
+	fmt.Println("REUSE TIME HISTOGRAM:")
+	for _, bucket := range analyzer.ReuseHistogram() {
+		fmt.Printf("  %d-%d: %d\n", bucket.Low, bucket.High, bucket.Count)
+	}
+
+	sizes := conf.sizes
+	if sizes == nil {
+		sizes = analyzer.DefaultSizes()
+	}
+	fmt.Println("LRU MISS RATIO CURVE:")
+	for _, point := range analyzer.MissRatioCurve(sizes) {
+		fmt.Printf("  %d: %.4f\n", point.Size, point.MissRatio)
+	}
+
+	if conf.window > 0 {
+		fmt.Println("WORKING SET PER", conf.window, "REQUESTS:")
+		for i, unique := range analyzer.WorkingSets() {
+			fmt.Printf("  %d: %d\n", i*conf.window, unique)
+		}
+	}
+
+	if conf.dataFile == "" {
+		return
+	}
+	records, err := dataset.Read(conf.dataFile)
+	if err != nil {
+		fmt.Println("ERROR reading dataset: ", err)
+		os.Exit(-1)
+	}
+	costs := analyzer.JoinCosts(records)
+	fmt.Println("TRAFFIC COST WITHOUT CACHE:", costs.Total)
+	printCosts("COST PER REQUEST", costs.Requests)
+	printCosts("COST PER KEY", costs.Keys)
+	fmt.Printf("POPULARITY/COST CORRELATION: %.3f\n", costs.Correlation)
+	if costs.Missing > 0 {
+		fmt.Println("KEYS MISSING FROM DATASET:", costs.Missing)
+	}
+}
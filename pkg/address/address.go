@@ -0,0 +1,30 @@
+package address
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*Split reads a -listen style address, either host:port or unix:
+and a socket path, into the host, port and socket it stands for.
+The server and the client both take their address this way*/
+func Split(address string) (string, int, string, error) {
+	if strings.HasPrefix(address, "unix:") {
+		socket := strings.TrimPrefix(address, "unix:")
+		if socket == "" {
+			return "", 0, "", errors.New("No socket path after unix:")
+		}
+		return "", 0, socket, nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, "", err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 0 || portNumber > 65535 {
+		return "", 0, "", errors.New("Bad port " + strconv.Quote(port))
+	}
+	return host, portNumber, "", nil
+}
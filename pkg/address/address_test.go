@@ -0,0 +1,35 @@
+package address
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		address string
+		host    string
+		port    int
+		socket  string
+		err     string
+	}{
+		{"localhost:1235", "localhost", 1235, "", ""},
+		{":1234", "", 1234, "", ""},
+		{"[::1]:80", "::1", 80, "", ""},
+		{"unix:/tmp/lcr-cache.sock", "", 0, "/tmp/lcr-cache.sock", ""},
+		{"unix:relative.sock", "", 0, "relative.sock", ""},
+		{"unix:", "", 0, "", "No socket path after unix:"},
+		{"localhost", "", 0, "", "address localhost: missing port in address"},
+		{"localhost:http", "", 0, "", `Bad port "http"`},
+		{"localhost:70000", "", 0, "", `Bad port "70000"`},
+	}
+	for _, c := range cases {
+		host, port, socket, err := Split(c.address)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v, want %q", c.address, err, c.err)
+			}
+			continue
+		}
+		if err != nil || host != c.host || port != c.port || socket != c.socket {
+			t.Errorf("%s: got %q, %d, %q, %v", c.address, host, port, socket, err)
+		}
+	}
+}
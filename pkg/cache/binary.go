@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"net"
+
+	"github.com/evizitei/lcr-cache/pkg/wire"
+)
+
+/*handleBinaryConnection speaks the length-prefixed binary
+protocol from pkg/wire, which (unlike the text protocol) has
+no trouble with commas, colons or newlines in values and takes
+frames up to wire.MaxFrameSize.  A connection carries many requests*/
+func (s *Server) handleBinaryConnection(c net.Conn, conn int64) {
+	defer c.Close()
+	reader := bufio.NewReader(c)
+	writer := bufio.NewWriter(c)
+	for {
+		req, err := wire.ReadRequest(reader)
+		if err != nil {
+			if err != io.EOF {
+				s.logger.Println("Conn error: ", err.Error())
+				wire.WriteResponse(writer, &wire.Response{Status: wire.StatusBadRequest})
+			}
+			return
+		}
+		err = wire.WriteResponse(writer, s.binaryResponse(req, conn))
+		if err == wire.ErrFrameTooLarge {
+			// nothing went out, so the connection is still in step
+			s.logger.Println("Response too large for a frame")
+			err = wire.WriteResponse(writer, &wire.Response{Status: wire.StatusServerError})
+		}
+		if err != nil {
+			s.logger.Println("Conn error: ", err.Error())
+			return
+		}
+		if s.isClosing() {
+			return
+		}
+	}
+}
+
+func (s *Server) binaryResponse(req *wire.Request, conn int64) *wire.Response {
+	if req.Op == wire.OpFetch || req.Op == wire.OpMFetch {
+		from := origin{conn, "fetch"}
+		if req.Op == wire.OpMFetch {
+			from.command = "mfetch"
+		}
+		if req.Op == wire.OpFetch && len(req.Keys) != 1 {
+			return &wire.Response{Status: wire.StatusBadRequest}
+		}
+		results := make([]wire.Result, 0, len(req.Keys))
+		for _, key := range req.Keys {
+			if s.config.Verbose {
+				s.logger.Println("Fetching ", key)
+			}
+			entry, hit, err := s.fetch(key, from)
+			if err != nil {
+				results = append(results, wire.Result{Status: wire.StatusNotFound})
+				continue
+			}
+			result := wire.Result{Status: wire.StatusOK, Hit: hit, Cost: int64(entry.cost), Value: entry.value}
+			if hit {
+				result.Cost, result.Saved = 0, int64(entry.cost)
+			}
+			results = append(results, result)
+		}
+		return &wire.Response{Status: wire.StatusOK, Results: results}
+	} else if req.Op == wire.OpDelete {
+		if len(req.Keys) != 1 {
+			return &wire.Response{Status: wire.StatusBadRequest}
+		}
+		result := wire.Result{Status: wire.StatusOK}
+		if s.remove(req.Keys[0], origin{conn, "delete"}) != nil {
+			result.Status = wire.StatusNotFound
+		}
+		return &wire.Response{Status: wire.StatusOK, Results: []wire.Result{result}}
+	} else if req.Op == wire.OpStats {
+		result := wire.Result{Status: wire.StatusOK, Value: s.Report().Summary()}
+		return &wire.Response{Status: wire.StatusOK, Results: []wire.Result{result}}
+	}
+	s.logger.Println("No such binary opcode: ", req.Op)
+	return &wire.Response{Status: wire.StatusBadRequest}
+}
@@ -14,6 +14,15 @@ type Cache interface {
 	KeyPresent(key string) bool
 	GetValue(key string) (Entry, error)
 	SetValue(key string, value Entry) error
+	DeleteValue(key string) error
+	Evictions() map[string]int
+}
+
+/*WeightedCache is implemented by the adaptive caches
+that choose between several expert policies, so the
+server can report how much each expert is trusted*/
+type WeightedCache interface {
+	Weights() map[string]float64
 }
 
 /*NoOp is a dummy implementation.  No keys are ever present,
@@ -31,6 +40,14 @@ func (cno *NoOp) GetValue(k string) (Entry, error) {
 /*SetValue does nothing in the no-op cache*/
 func (cno *NoOp) SetValue(k string, v Entry) error { return nil }
 
+/*DeleteValue will always return an error for the no-op cache*/
+func (cno *NoOp) DeleteValue(k string) error {
+	return errors.New("Key not present")
+}
+
+/*Evictions is always empty for the no-op cache*/
+func (cno *NoOp) Evictions() map[string]int { return map[string]int{} }
+
 /*useful for easily tracking the "oldest" added node in the
 cache*/
 type fifoNode struct {
@@ -43,11 +60,12 @@ type fifoNode struct {
 /*FiFo is a First-in-fist-out cache implementation.
 When full, it will always decide to evict the oldest key added.*/
 type FiFo struct {
-	maxSize int
-	length  int
-	head    *fifoNode
-	tail    *fifoNode
-	lookup  map[string]*fifoNode
+	maxSize   int
+	length    int
+	head      *fifoNode
+	tail      *fifoNode
+	evictions int
+	lookup    map[string]*fifoNode
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
@@ -77,6 +95,7 @@ func (ff *FiFo) SetValue(k string, v Entry) error {
 		return nil
 	} else if ff.length == ff.maxSize {
 		// evict one entry
+		ff.evictions++
 		newNode := &fifoNode{entry: v, key: k}
 		prevHead := ff.head
 		delete(ff.lookup, prevHead.key)
@@ -102,6 +121,34 @@ func (ff *FiFo) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from the cache without counting it as an eviction*/
+func (ff *FiFo) DeleteValue(k string) error {
+	node, ok := ff.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if node.prev == nil {
+		ff.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		ff.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	delete(ff.lookup, k)
+	ff.length = ff.length - 1
+	return nil
+}
+
+/*Evictions counts the entries pushed out of the full cache*/
+func (ff *FiFo) Evictions() map[string]int {
+	return map[string]int{"FIFO": ff.evictions}
+}
+
 func newFifo(size int) *FiFo {
 	lk := make(map[string]*fifoNode)
 	return &FiFo{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk}
@@ -119,11 +166,12 @@ type lruNode struct {
 /*Lru is a cache implementation adapting to access time.
 When full, it will always decide to evict the key touched the longest ago.*/
 type Lru struct {
-	maxSize int
-	length  int
-	head    *lruNode
-	tail    *lruNode
-	lookup  map[string]*lruNode
+	maxSize   int
+	length    int
+	head      *lruNode
+	tail      *lruNode
+	evictions int
+	lookup    map[string]*lruNode
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
@@ -178,6 +226,7 @@ func (l *Lru) SetValue(k string, v Entry) error {
 		return nil
 	} else if l.length == l.maxSize {
 		// evict one entry
+		l.evictions++
 		newNode := &lruNode{entry: v, key: k}
 		prevHead := l.head
 		delete(l.lookup, prevHead.key)
@@ -203,6 +252,34 @@ func (l *Lru) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from the cache without counting it as an eviction*/
+func (l *Lru) DeleteValue(k string) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if node.prev == nil {
+		l.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		l.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	delete(l.lookup, k)
+	l.length = l.length - 1
+	return nil
+}
+
+/*Evictions counts the entries pushed out of the full cache*/
+func (l *Lru) Evictions() map[string]int {
+	return map[string]int{"LRU": l.evictions}
+}
+
 func newLru(size int) *Lru {
 	lk := make(map[string]*lruNode)
 	return &Lru{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk}
@@ -221,12 +298,13 @@ type lfuNode struct {
 /*Lfu is a cache implementation adapting to access frequency.
 When full, it will always decide to evict the key touched the least number of times.*/
 type Lfu struct {
-	maxSize int
-	length  int
-	head    *lfuNode
-	tail    *lfuNode
-	lookup  map[string]*lfuNode
-	debug   bool
+	maxSize   int
+	length    int
+	head      *lfuNode
+	tail      *lfuNode
+	evictions int
+	lookup    map[string]*lfuNode
+	debug     bool
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
@@ -326,6 +404,7 @@ func (l *Lfu) SetValue(k string, v Entry) error {
 		return nil
 	} else if l.length == l.maxSize {
 		// evict one entry
+		l.evictions++
 		newNode := &lfuNode{entry: v, key: k, accessCount: 1}
 		prevHead := l.head
 		delete(l.lookup, prevHead.key)
@@ -357,6 +436,34 @@ func (l *Lfu) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from the cache without counting it as an eviction*/
+func (l *Lfu) DeleteValue(k string) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if node.prev == nil {
+		l.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		l.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	delete(l.lookup, k)
+	l.length = l.length - 1
+	return nil
+}
+
+/*Evictions counts the entries pushed out of the full cache*/
+func (l *Lfu) Evictions() map[string]int {
+	return map[string]int{"LFU": l.evictions}
+}
+
 func newLfu(size int) *Lfu {
 	lk := make(map[string]*lfuNode)
 	return &Lfu{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk, debug: false}
@@ -374,12 +481,13 @@ type lcrNode struct {
 /*Lcr is a cache implementation adapting to cost of recomputation.
 When full, it will always decide to evict the key with the lowest cost to recompute.*/
 type Lcr struct {
-	maxSize int
-	length  int
-	head    *lcrNode
-	tail    *lcrNode
-	lookup  map[string]*lcrNode
-	debug   bool
+	maxSize   int
+	length    int
+	head      *lcrNode
+	tail      *lcrNode
+	evictions int
+	lookup    map[string]*lcrNode
+	debug     bool
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
@@ -471,6 +579,7 @@ func (l *Lcr) SetValue(k string, v Entry) error {
 		return nil
 	} else if l.length == l.maxSize {
 		// evict one entry
+		l.evictions++
 		newNode := &lcrNode{entry: v, key: k}
 		prevHead := l.head
 		delete(l.lookup, prevHead.key)
@@ -502,6 +611,34 @@ func (l *Lcr) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from the cache without counting it as an eviction*/
+func (l *Lcr) DeleteValue(k string) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if node.prev == nil {
+		l.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		l.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	delete(l.lookup, k)
+	l.length = l.length - 1
+	return nil
+}
+
+/*Evictions counts the entries pushed out of the full cache*/
+func (l *Lcr) Evictions() map[string]int {
+	return map[string]int{"LCR": l.evictions}
+}
+
 func newLcr(size int) *Lcr {
 	lk := make(map[string]*lcrNode)
 	return &Lcr{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk, debug: false}
@@ -63,6 +63,7 @@ type Calecar struct {
 	historyTail   *calecarHistoryNode
 	lambda        float64
 	discount      float64
+	evictions     map[string]int
 }
 
 func (c *Calecar) updateAlgoWeights(node *calecarHistoryNode) {
@@ -354,6 +355,7 @@ func (c *Calecar) appendToLru(lruNode *calecarLruNode) {
 
 func (c *Calecar) putInHistory(entryNode *calecarLookupNode, evictionType string) {
 	historyNode := &calecarHistoryNode{key: entryNode.key, evictionType: evictionType}
+	c.evictions[evictionType]++
 	// TAIL will be most recently added
 	// HEAD will be earliest added, first to remove
 	if c.historyLength == 0 {
@@ -483,6 +485,49 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from every expert's list without
+counting it as an eviction, so no expert is blamed for it later*/
+func (c *Calecar) DeleteValue(k string) error {
+	lookupNode, ok := c.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if c.length == 1 {
+		// last entry, the lists are empty again
+		c.lruHead = nil
+		c.lruTail = nil
+		c.lfuHead = nil
+		c.lfuTail = nil
+		c.lcrHead = nil
+		c.lcrTail = nil
+	} else {
+		c.removeFromLru(lookupNode.lruNode)
+		c.removeFromLfu(lookupNode.lfuNode)
+		c.removeFromLcr(lookupNode.lcrNode)
+	}
+	delete(c.lookup, k)
+	c.length = c.length - 1
+	return nil
+}
+
+/*Weights reports the current trust in each expert policy*/
+func (c *Calecar) Weights() map[string]float64 {
+	return map[string]float64{
+		"LRU": c.weightLru,
+		"LFU": c.weightLfu,
+		"LCR": c.weightLcr,
+	}
+}
+
+/*Evictions counts the entries pushed out of the full cache by each expert policy*/
+func (c *Calecar) Evictions() map[string]int {
+	return map[string]int{
+		"LRU": c.evictions["LRU"],
+		"LFU": c.evictions["LFU"],
+		"LCR": c.evictions["LCR"],
+	}
+}
+
 func newCalecar(size int) *Calecar {
 	lk := make(map[string]*calecarLookupNode)
 	hk := make(map[string]*calecarHistoryNode)
@@ -505,5 +550,6 @@ func newCalecar(size int) *Calecar {
 		historyLength: 0,
 		lambda:        0.45,
 		discount:      0.99,
+		evictions:     make(map[string]int),
 	}
 }
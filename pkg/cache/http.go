@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*fetchResponse is the JSON body for GET /keys/{key}.  Cost
+is what the request paid, so it's 0 on a hit, and saved is
+what a hit avoided paying, the same as the COST and SAVED
+lines of the tcp protocol*/
+type fetchResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Cost  int    `json:"cost"`
+	Saved int    `json:"saved"`
+	Hit   bool   `json:"hit"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+/*handleKey serves GET and DELETE on /keys/{key}*/
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	if key == "" {
+		writeJSONError(w, http.StatusNotFound, "No key given")
+		return
+	}
+	if r.Method == http.MethodGet {
+		if s.config.Verbose {
+			s.logger.Println("Fetching ", key)
+		}
+		entry, hit, err := s.fetch(key, origin{httpConn(r), "get"})
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		response := fetchResponse{Key: key, Value: entry.value, Cost: entry.cost, Hit: hit}
+		w.Header().Set("X-Cache", "MISS")
+		if hit {
+			response.Cost, response.Saved = 0, entry.cost
+			w.Header().Set("X-Cache", "HIT")
+		}
+		w.Header().Set("X-Cost", strconv.Itoa(response.Cost))
+		w.Header().Set("X-Saved", strconv.Itoa(response.Saved))
+		writeJSON(w, http.StatusOK, response)
+	} else if r.Method == http.MethodDelete {
+		err := s.remove(key, origin{httpConn(r), "delete"})
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET or DELETE")
+	}
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Report())
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	err := s.flush(origin{httpConn(r), "reset"}, true)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Println("Cache and counters reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*connKey is where an HTTP request's context keeps its connection id*/
+type connKey struct{}
+
+func httpConn(r *http.Request) int64 {
+	conn, _ := r.Context().Value(connKey{}).(int64)
+	return conn
+}
+
+/*startHTTP serves the HTTP/JSON front end, sharing
+the cache with the tcp listener*/
+func (s *Server) startHTTP() *http.Server {
+	ln := s.listenExtra("HTTP", s.config.HTTPPort)
+	front := &http.Server{Handler: s.httpHandler()}
+	front.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		return context.WithValue(ctx, connKey{}, s.nextConn())
+	}
+	go func() {
+		err := front.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Println("ERROR serving HTTP: ", err)
+		}
+	}()
+	return front
+}
+
+func (s *Server) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/", s.handleKey)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/admin/reset", s.handleReset)
+	return mux
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*request sends one request to the HTTP front end, returning the
+status, the headers and the body*/
+func request(t *testing.T, front *httptest.Server, method string, path string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, front.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, string(body)
+}
+
+func TestHTTPFrontEnd(t *testing.T) {
+	s := newTestServer(t, "LRU")
+	front := httptest.NewServer(s.httpHandler())
+	defer front.Close()
+	cases := []struct {
+		method string
+		path   string
+		status int
+		cache  string
+		body   string
+	}{
+		{"GET", "/keys/key1", 200, "MISS", `{"key":"key1","value":"val1","cost":10,"saved":0,"hit":false}`},
+		{"GET", "/keys/key1", 200, "HIT", `{"key":"key1","value":"val1","cost":0,"saved":10,"hit":true}`},
+		{"GET", "/keys/nope", 404, "", `{"error":"No Entry For Key: nope"}`},
+		{"GET", "/keys/", 404, "", `{"error":"No key given"}`},
+		{"PUT", "/keys/key1", 405, "", `{"error":"Use GET or DELETE"}`},
+		{"DELETE", "/keys/key1", 204, "", ""},
+		{"DELETE", "/keys/key1", 404, "", ""},
+		{"GET", "/keys/key1", 200, "MISS", `{"key":"key1","value":"val1","cost":10,"saved":0,"hit":false}`},
+		{"POST", "/stats", 405, "", `{"error":"Use GET"}`},
+		{"GET", "/admin/reset", 405, "", `{"error":"Use POST"}`},
+	}
+	for _, c := range cases {
+		status, header, body := request(t, front, c.method, c.path)
+		body = strings.TrimSpace(body)
+		if status != c.status {
+			t.Errorf("%s %s: got status %d, want %d", c.method, c.path, status, c.status)
+		}
+		if c.cache != "" && header.Get("X-Cache") != c.cache {
+			t.Errorf("%s %s: got X-Cache %q, want %q", c.method, c.path, header.Get("X-Cache"), c.cache)
+		}
+		if c.body != "" && body != c.body {
+			t.Errorf("%s %s: got body %s, want %s", c.method, c.path, body, c.body)
+		}
+		if body != "" && header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: got content type %q", c.method, c.path, header.Get("Content-Type"))
+		}
+	}
+	_, header, _ := request(t, front, "GET", "/keys/key1")
+	if header.Get("X-Cost") != "0" || header.Get("X-Saved") != "10" {
+		t.Errorf("a hit got X-Cost %q and X-Saved %q", header.Get("X-Cost"), header.Get("X-Saved"))
+	}
+
+	status, _, body := request(t, front, "GET", "/stats")
+	report := Report{}
+	err := json.Unmarshal([]byte(body), &report)
+	if status != 200 || err != nil {
+		t.Fatalf("stats: got status %d and %v", status, err)
+	}
+	if report.Requests != 4 || report.Hits != 2 || report.CostSaved != 20 || report.CacheType != "LRU" {
+		t.Errorf("stats: got %+v", report)
+	}
+
+	status, _, _ = request(t, front, "POST", "/admin/reset")
+	if status != 204 {
+		t.Errorf("reset: got status %d, want 204", status)
+	}
+	if !reflect.DeepEqual(s.Report(), newTestServer(t, "LRU").Report()) {
+		t.Errorf("reset left %+v", s.Report())
+	}
+	_, header, _ = request(t, front, "GET", "/keys/key1")
+	if header.Get("X-Cache") != "MISS" {
+		t.Error("reset should empty the cache")
+	}
+}
@@ -53,6 +53,7 @@ type Lecar struct {
 	historyTail   *lecarHistoryNode
 	lambda        float64
 	discount      float64
+	evictions     map[string]int
 }
 
 func (l *Lecar) updateAlgoWeights(node *lecarHistoryNode) {
@@ -268,6 +269,7 @@ func (l *Lecar) appendToLru(lruNode *lecarLruNode) {
 
 func (l *Lecar) putInHistory(entryNode *lecarLookupNode, evictionType string) {
 	historyNode := &lecarHistoryNode{key: entryNode.key, evictionType: evictionType}
+	l.evictions[evictionType]++
 	// TAIL will be most recently added
 	// HEAD will be earliest added, first to remove
 	if l.historyLength == 0 {
@@ -369,6 +371,44 @@ func (l *Lecar) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*DeleteValue drops an entry from every expert's list without
+counting it as an eviction, so no expert is blamed for it later*/
+func (l *Lecar) DeleteValue(k string) error {
+	lookupNode, ok := l.lookup[k]
+	if !ok {
+		return errors.New("Key not present in lookup hash")
+	}
+	if l.length == 1 {
+		// last entry, the lists are empty again
+		l.lruHead = nil
+		l.lruTail = nil
+		l.lfuHead = nil
+		l.lfuTail = nil
+	} else {
+		l.removeFromLru(lookupNode.lruNode)
+		l.removeFromLfu(lookupNode.lfuNode)
+	}
+	delete(l.lookup, k)
+	l.length = l.length - 1
+	return nil
+}
+
+/*Weights reports the current trust in each expert policy*/
+func (l *Lecar) Weights() map[string]float64 {
+	return map[string]float64{
+		"LRU": l.weightLru,
+		"LFU": l.weightLfu,
+	}
+}
+
+/*Evictions counts the entries pushed out of the full cache by each expert policy*/
+func (l *Lecar) Evictions() map[string]int {
+	return map[string]int{
+		"LRU": l.evictions["LRU"],
+		"LFU": l.evictions["LFU"],
+	}
+}
+
 func newLecar(size int) *Lecar {
 	lk := make(map[string]*lecarLookupNode)
 	hk := make(map[string]*lecarHistoryNode)
@@ -388,6 +428,7 @@ func newLecar(size int) *Lecar {
 		historyLength: 0,
 		lambda:        0.45,
 		discount:      0.99,
+		evictions:     make(map[string]int),
 		debug:         false,
 	}
 }
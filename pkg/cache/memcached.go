@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*memcachedItemMax is the largest value a set may store, memcached's
+own default item size limit*/
+const memcachedItemMax = 1024 * 1024
+
+/*handleMemcachedConnection speaks the memcached text protocol
+(get, gets, set, delete, stats, flush_all) so existing memcached
+clients and load generators can drive the cache.  Unlike the
+fetch protocol, a connection stays open for many commands*/
+func (s *Server) handleMemcachedConnection(c net.Conn, conn int64) {
+	defer c.Close()
+	reader := bufio.NewReader(c)
+	writer := bufio.NewWriter(c)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				s.logger.Println("Conn error: ", err.Error())
+			}
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		command := fields[0]
+		if command == "get" || command == "gets" {
+			s.memcachedGet(writer, fields[1:], command == "gets", origin{conn, command})
+		} else if command == "set" {
+			if !s.memcachedSet(reader, writer, fields[1:], origin{conn, command}) {
+				writer.Flush()
+				return
+			}
+		} else if command == "delete" {
+			s.memcachedDelete(writer, fields[1:], origin{conn, command})
+		} else if command == "stats" {
+			s.memcachedStats(writer)
+		} else if command == "flush_all" {
+			err := s.flush(origin{conn, command}, false)
+			if err != nil {
+				writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+			} else if !noReply(fields) {
+				writer.WriteString("OK\r\n")
+			}
+		} else if command == "quit" {
+			writer.Flush()
+			return
+		} else {
+			s.logger.Println("No such memcached command: ", command)
+			writer.WriteString("ERROR\r\n")
+		}
+		writer.Flush()
+		if s.isClosing() {
+			return
+		}
+	}
+}
+
+func noReply(fields []string) bool {
+	return len(fields) > 0 && fields[len(fields)-1] == "noreply"
+}
+
+/*memcachedGet fetches every key in order, so the policy
+sees the same sequence a series of fetch commands would.
+Keys that aren't in the dataset are left out of the response*/
+func (s *Server) memcachedGet(w *bufio.Writer, keys []string, withCas bool, from origin) {
+	if len(keys) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		if s.config.Verbose {
+			s.logger.Println("Fetching ", key)
+		}
+		entry, _, err := s.fetch(key, from)
+		if err != nil {
+			continue
+		}
+		header := "VALUE " + key + " 0 " + strconv.Itoa(len(entry.value))
+		if withCas {
+			header += " 0"
+		}
+		w.WriteString(header + "\r\n" + entry.value + "\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+/*memcachedSet reads "set <key> <flags> <exptime> <bytes> [noreply]"
+and its data block, turning away blocks over memcachedItemMax bytes.
+It returns false when the connection can't be read from any more*/
+func (s *Server) memcachedSet(r *bufio.Reader, w *bufio.Writer, args []string, from origin) bool {
+	if len(args) < 4 {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	length, err := strconv.Atoi(args[3])
+	if err != nil || length < 0 {
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+	if length > memcachedItemMax {
+		// swallow the data block like memcached does, so the next
+		// command lines up
+		_, err = io.CopyN(ioutil.Discard, r, int64(length))
+		if err == nil {
+			_, err = io.CopyN(ioutil.Discard, r, 2)
+		}
+		if err != nil {
+			s.logger.Println("Conn error: ", err.Error())
+			return false
+		}
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return true
+	}
+	data := make([]byte, length+2)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		s.logger.Println("Conn error: ", err.Error())
+		return false
+	}
+	if string(data[length:]) != "\r\n" {
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+	s.store(args[0], string(data[:length]), from)
+	if !noReply(args) {
+		w.WriteString("STORED\r\n")
+	}
+	return true
+}
+
+func (s *Server) memcachedDelete(w *bufio.Writer, args []string, from origin) {
+	if len(args) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	err := s.remove(args[0], from)
+	if noReply(args) {
+		return
+	}
+	if err != nil {
+		w.WriteString("NOT_FOUND\r\n")
+	} else {
+		w.WriteString("DELETED\r\n")
+	}
+}
+
+func (s *Server) memcachedStats(w *bufio.Writer) {
+	report := s.Report()
+	stats := [][]string{
+		{"cache_type", report.CacheType},
+		{"limit_maxitems", strconv.Itoa(report.CacheSize)},
+		{"cmd_get", strconv.FormatInt(report.Requests, 10)},
+		{"get_hits", strconv.FormatInt(report.Hits, 10)},
+		{"get_misses", strconv.FormatInt(report.Misses, 10)},
+		{"evictions", strconv.Itoa(report.TotalEvictions())},
+		{"cost_served", strconv.FormatInt(report.CostServed, 10)},
+		{"cost_saved", strconv.FormatInt(report.CostSaved, 10)},
+	}
+	for _, stat := range stats {
+		w.WriteString("STAT " + stat[0] + " " + stat[1] + "\r\n")
+	}
+	w.WriteString("END\r\n")
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMemcachedProtocol(t *testing.T) {
+	tooLarge := strconv.Itoa(memcachedItemMax + 1)
+	cases := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"get", "get key1\r\n", "VALUE key1 0 4\r\nval1\r\nEND\r\n"},
+		{"get several", "get key1 nope key2\r\n", "VALUE key1 0 4\r\nval1\r\nVALUE key2 0 4\r\nval2\r\nEND\r\n"},
+		{"gets", "gets key1\r\n", "VALUE key1 0 4 0\r\nval1\r\nEND\r\n"},
+		{"get without a key", "get\r\n", "ERROR\r\n"},
+		{"set then get", "set key1 0 0 3\r\nnew\r\nget key1\r\n", "STORED\r\nVALUE key1 0 3\r\nnew\r\nEND\r\n"},
+		{"set noreply", "set key1 0 0 3 noreply\r\nnew\r\nget key1\r\n", "VALUE key1 0 3\r\nnew\r\nEND\r\n"},
+		{"set short line", "set key1 0 0\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"set bad length", "set key1 0 0 x\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"set negative length", "set key1 0 0 -1\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"set bad terminator", "set key1 0 0 3\r\nnewX\r\nget key2\r\n", "CLIENT_ERROR bad data chunk\r\nVALUE key2 0 4\r\nval2\r\nEND\r\n"},
+		{"set too large", "set key1 0 0 " + tooLarge + "\r\n" + strings.Repeat("x", memcachedItemMax+1) + "\r\nget key1\r\n",
+			"SERVER_ERROR object too large for cache\r\nVALUE key1 0 4\r\nval1\r\nEND\r\n"},
+		{"set huge", "set key1 0 0 9000000000000000000\r\nxyz\r\n", ""},
+		{"set truncated", "set key1 0 0 10\r\nabc", ""},
+		{"delete", "get key1\r\ndelete key1\r\ndelete key1\r\n", "VALUE key1 0 4\r\nval1\r\nEND\r\nDELETED\r\nNOT_FOUND\r\n"},
+		{"flush_all", "flush_all\r\nflush_all noreply\r\n", "OK\r\n"},
+		{"unknown command", "frobnicate\r\n", "ERROR\r\n"},
+		{"quit", "quit\r\nget key1\r\n", ""},
+	}
+	for _, c := range cases {
+		s := newTestServer(t, "LRU")
+		output := converse(t, s.handleMemcachedConnection, c.input)
+		if output != c.output {
+			t.Errorf("%s: got %q, want %q", c.name, output, c.output)
+		}
+	}
+}
+
+func TestMemcachedCountsHits(t *testing.T) {
+	s := newTestServer(t, "LRU")
+	converse(t, s.handleMemcachedConnection, "get key1\r\nget key1\r\nget key2\r\n")
+	report := s.Report()
+	if report.Requests != 3 || report.Hits != 1 || report.Misses != 2 {
+		t.Errorf("got %d requests, %d hits, %d misses, want 3, 1, 2", report.Requests, report.Hits, report.Misses)
+	}
+}
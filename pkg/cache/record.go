@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*origin is where a request came from: the connection it arrived
+on and the protocol's name for the command*/
+type origin struct {
+	conn    int64
+	command string
+}
+
+/*What happened to a recorded request*/
+const (
+	recordHit      = "H"
+	recordMiss     = "M"
+	recordNotFound = "N"
+	recordWrite    = "-"
+)
+
+/*recorder appends every keyed request the server handles to a
+trace file, one CSV row of microseconds since the server started,
+connection id, command, key, result, the key's cost and, for sets,
+the value stored as a Go quoted string.  Flushes and resets get a row too, with no key.
+Rows are written under the cache lock, so they're in exactly the
+order the cache saw them, and replaying the file against the same
+policy gives the same results for all but the policies that choose
+at random (LECAR and CALECAR), which only come out close*/
+type recorder struct {
+	lock  sync.Mutex
+	file  *os.File
+	out   *bufio.Writer
+	csv   *csv.Writer
+	began time.Time
+}
+
+func newRecorder(filename string) (*recorder, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	out := bufio.NewWriterSize(file, 64*1024)
+	out.WriteString("# timestamp_us,conn,command,key,result,cost[,value]\n")
+	return &recorder{file: file, out: out, csv: csv.NewWriter(out), began: time.Now()}, nil
+}
+
+func (r *recorder) record(from origin, key string, result string, cost int, value ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.csv.Write(append([]string{
+		strconv.FormatInt(time.Since(r.began).Microseconds(), 10),
+		strconv.FormatInt(from.conn, 10),
+		from.command,
+		key,
+		result,
+		strconv.Itoa(cost),
+	}, value...))
+}
+
+func (r *recorder) close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.csv.Flush()
+	err := r.csv.Error()
+	if err == nil {
+		err = r.out.Flush()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*TestRecordingReplays drives a server over several protocols,
+with sets, deletes, a flush and a reset along the way, then checks
+that simulating its recording ends with the same report*/
+func TestRecordingReplays(t *testing.T) {
+	for _, cacheType := range []string{"FIFO", "LRU", "LFU", "LCR"} {
+		conf := testConf(t, cacheType)
+		conf.RecordFile = filepath.Join(t.TempDir(), "recording.csv")
+		live := startTestServer(t, conf)
+		converse(t, live.handleMemcachedConnection, "get key1\r\nget key2\r\nget key1\r\n"+
+			"set key3 0 0 5\r\nhello\r\nget key3\r\nget key1\r\ndelete key2\r\nget key2\r\n"+
+			"set key9 0 0 2\r\nhi\r\nget key9 nope\r\n")
+		converse(t, live.handleRespConnection, "GET key3\r\n"+
+			"*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$8\r\na,\"b\r\nc\"\r\nMGET key1 key2 key3\r\n")
+		converse(t, live.handleConnection, "mfetch,key1,key2,key3,key9\n")
+		converse(t, live.handleMemcachedConnection, "flush_all\r\nget key1\r\nget key1\r\n")
+		converse(t, live.handleConnection, "fetch,key2\n")
+		err := live.flush(origin{command: "reset"}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converse(t, live.handleConnection, "mfetch,key3,key1,key2,key3,key9\n")
+		want := live.Report()
+		live.finalReport()
+
+		replay := startTestServer(t, testConf(t, cacheType))
+		err = replay.Simulate([]string{conf.RecordFile}, "recording")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := replay.Report()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: replay reported %+v, the live server %+v", cacheType, got, want)
+		}
+		if want.Requests != 5 || want.Bytes == 0 {
+			t.Errorf("%s: the reset should leave 5 requests counted, got %+v", cacheType, want)
+		}
+	}
+}
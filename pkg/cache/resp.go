@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*The largest commands readRespCommand takes, redis' own limits on
+the number of arguments and the length of each*/
+const (
+	respMultibulkMax = 1024 * 1024
+	respBulkMax      = 512 * 1024 * 1024
+)
+
+/*readRespCommand reads one command, either as a RESP array of
+bulk strings (what redis clients send) or as an inline line of
+space separated words (what you'd type into telnet).  Arguments
+are read as they arrive rather than allocated up front, so a
+header can't make the server reserve more than the client sends*/
+func readRespCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > respMultibulkMax {
+		return nil, errors.New("Protocol error: invalid multibulk length")
+	}
+	args := []string{}
+	for i := 0; i < count; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = strings.TrimRight(header, "\r\n")
+		if !strings.HasPrefix(header, "$") {
+			return nil, errors.New("Protocol error: expected '$', got '" + header + "'")
+		}
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > respBulkMax {
+			return nil, errors.New("Protocol error: invalid bulk length")
+		}
+		var data bytes.Buffer
+		_, err = io.CopyN(&data, r, int64(length)+2)
+		if err != nil {
+			return nil, err
+		}
+		if string(data.Bytes()[length:]) != "\r\n" {
+			// the length and the data disagree, so nothing after
+			// this can be trusted to line up
+			return nil, errors.New("Protocol error: bulk data not followed by CRLF")
+		}
+		args = append(args, string(data.Bytes()[:length]))
+	}
+	return args, nil
+}
+
+func writeRespBulk(w *bufio.Writer, value string) {
+	w.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
+
+func writeRespNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeRespArray(w *bufio.Writer, length int) {
+	w.WriteString("*" + strconv.Itoa(length) + "\r\n")
+}
+
+func writeRespInt(w *bufio.Writer, value int) {
+	w.WriteString(":" + strconv.Itoa(value) + "\r\n")
+}
+
+func writeRespError(w *bufio.Writer, message string) {
+	w.WriteString("-ERR " + message + "\r\n")
+}
+
+/*handleRespConnection speaks enough of the redis protocol
+(GET, MGET, SET, DEL, INFO, PING and CACHE.WEIGHTS) for
+redis-cli and redis-benchmark to drive the cache*/
+func (s *Server) handleRespConnection(c net.Conn, conn int64) {
+	defer c.Close()
+	reader := bufio.NewReader(c)
+	writer := bufio.NewWriter(c)
+	for {
+		args, err := readRespCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				s.logger.Println("Conn error: ", err.Error())
+				writeRespError(writer, err.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		command := strings.ToUpper(args[0])
+		if command == "QUIT" {
+			writer.WriteString("+OK\r\n")
+			writer.Flush()
+			return
+		}
+		s.respCommand(writer, command, args[1:], origin{conn, strings.ToLower(command)})
+		writer.Flush()
+		if s.isClosing() {
+			return
+		}
+	}
+}
+
+func (s *Server) respCommand(w *bufio.Writer, command string, args []string, from origin) {
+	if command == "GET" {
+		if len(args) != 1 {
+			writeRespError(w, "wrong number of arguments for 'get' command")
+			return
+		}
+		entry, _, err := s.fetch(args[0], from)
+		if err != nil {
+			writeRespNull(w)
+			return
+		}
+		writeRespBulk(w, entry.value)
+	} else if command == "MGET" {
+		if len(args) == 0 {
+			writeRespError(w, "wrong number of arguments for 'mget' command")
+			return
+		}
+		// fetched in order so the policy sees the same sequence
+		// a series of GETs would produce
+		writeRespArray(w, len(args))
+		for _, key := range args {
+			entry, _, err := s.fetch(key, from)
+			if err != nil {
+				writeRespNull(w)
+			} else {
+				writeRespBulk(w, entry.value)
+			}
+		}
+	} else if command == "SET" {
+		if len(args) < 2 {
+			writeRespError(w, "wrong number of arguments for 'set' command")
+			return
+		}
+		s.store(args[0], args[1], from)
+		w.WriteString("+OK\r\n")
+	} else if command == "DEL" {
+		if len(args) == 0 {
+			writeRespError(w, "wrong number of arguments for 'del' command")
+			return
+		}
+		removed := 0
+		for _, key := range args {
+			if s.remove(key, from) == nil {
+				removed++
+			}
+		}
+		writeRespInt(w, removed)
+	} else if command == "INFO" {
+		writeRespBulk(w, s.respInfo())
+	} else if command == "CACHE.WEIGHTS" {
+		weights := s.Report().Weights
+		experts := make([]string, 0, len(weights))
+		for expert := range weights {
+			experts = append(experts, expert)
+		}
+		sort.Strings(experts)
+		writeRespArray(w, 2*len(experts))
+		for _, expert := range experts {
+			writeRespBulk(w, expert)
+			writeRespBulk(w, strconv.FormatFloat(weights[expert], 'f', -1, 64))
+		}
+	} else if command == "PING" {
+		if len(args) > 0 {
+			writeRespBulk(w, args[0])
+		} else {
+			w.WriteString("+PONG\r\n")
+		}
+	} else {
+		s.logger.Println("No such redis command: ", command)
+		writeRespError(w, "unknown command '"+command+"'")
+	}
+}
+
+/*respInfo renders the server counters the way redis INFO does*/
+func (s *Server) respInfo() string {
+	report := s.Report()
+	info := "# Cache\r\n" +
+		"cache_type:" + report.CacheType + "\r\n" +
+		"cache_size:" + strconv.Itoa(report.CacheSize) + "\r\n" +
+		"\r\n# Stats\r\n" +
+		"total_commands_processed:" + strconv.FormatInt(report.Requests, 10) + "\r\n" +
+		"keyspace_hits:" + strconv.FormatInt(report.Hits, 10) + "\r\n" +
+		"keyspace_misses:" + strconv.FormatInt(report.Misses, 10) + "\r\n" +
+		"evicted_keys:" + strconv.Itoa(report.TotalEvictions()) + "\r\n" +
+		"cost_served:" + strconv.FormatInt(report.CostServed, 10) + "\r\n" +
+		"cost_saved:" + strconv.FormatInt(report.CostSaved, 10) + "\r\n"
+	for _, expert := range sortedExperts(report.Evictions) {
+		info += "evicted_keys_" + strings.ToLower(expert) + ":" + strconv.Itoa(report.Evictions[expert]) + "\r\n"
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRespCommand(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		args  []string
+		err   string
+	}{
+		{"array", "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n", []string{"GET", "key1"}, ""},
+		{"empty bulk", "*2\r\n$3\r\nSET\r\n$0\r\n\r\n", []string{"SET", ""}, ""},
+		{"bulk with a newline", "*1\r\n$4\r\na\r\nb\r\n", []string{"a\r\nb"}, ""},
+		{"empty array", "*0\r\n", []string{}, ""},
+		{"inline", "GET key1\r\n", []string{"GET", "key1"}, ""},
+		{"inline without a carriage return", "PING\n", []string{"PING"}, ""},
+		{"negative count", "*-1\r\n", nil, "Protocol error: invalid multibulk length"},
+		{"huge count", "*9000000000000000000\r\n", nil, "Protocol error: invalid multibulk length"},
+		{"bad count", "*x\r\n", nil, "Protocol error: invalid multibulk length"},
+		{"missing dollar", "*1\r\nGET\r\n", nil, "Protocol error: expected '$', got 'GET'"},
+		{"negative length", "*1\r\n$-1\r\n", nil, "Protocol error: invalid bulk length"},
+		{"huge length", "*1\r\n$9000000000000000000\r\n", nil, "Protocol error: invalid bulk length"},
+		{"over the bulk limit", "*1\r\n$536870913\r\n", nil, "Protocol error: invalid bulk length"},
+		{"bulk longer than its length", "*1\r\n$3\r\nGETX\r\n", nil, "Protocol error: bulk data not followed by CRLF"},
+		{"bulk shorter than its length", "*2\r\n$5\r\nGET\r\n$4\r\nkey1\r\n", nil, "Protocol error: bulk data not followed by CRLF"},
+		{"bulk ending in a bare newline", "*1\r\n$3\r\nGET\n\n", nil, "Protocol error: bulk data not followed by CRLF"},
+		{"truncated bulk", "*1\r\n$10\r\nabc", nil, io.EOF.Error()},
+		{"truncated array", "*2\r\n$3\r\nGET\r\n", nil, io.EOF.Error()},
+		{"nothing", "", nil, io.EOF.Error()},
+	}
+	for _, c := range cases {
+		args, err := readRespCommand(bufio.NewReader(strings.NewReader(c.input)))
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: got %q, want %q", c.name, args, c.args)
+		}
+	}
+}
+
+func TestRespProtocol(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"get", "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n", "$4\r\nval1\r\n"},
+		{"get unknown key", "GET nope\r\n", "$-1\r\n"},
+		{"mget", "MGET key1 nope\r\n", "*2\r\n$4\r\nval1\r\n$-1\r\n"},
+		{"set then get", "SET key1 new\r\nGET key1\r\n", "+OK\r\n$3\r\nnew\r\n"},
+		{"del", "GET key1\r\nDEL key1 key2\r\n", "$4\r\nval1\r\n:1\r\n"},
+		{"ping", "PING\r\nPING hi\r\n", "+PONG\r\n$2\r\nhi\r\n"},
+		{"wrong arguments", "GET\r\n", "-ERR wrong number of arguments for 'get' command\r\n"},
+		{"unknown command", "FROB\r\n", "-ERR unknown command 'FROB'\r\n"},
+		{"quit", "QUIT\r\nPING\r\n", "+OK\r\n"},
+		{"protocol error hangs up", "*-1\r\nPING\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		{"bulk out of step hangs up", "*1\r\n$3\r\nPINGX\r\nPING\r\n", "-ERR Protocol error: bulk data not followed by CRLF\r\n"},
+		{"huge bulk hangs up", "*1\r\n$9000000000000000000\r\nPING\r\n", "-ERR Protocol error: invalid bulk length\r\n"},
+	}
+	for _, c := range cases {
+		s := newTestServer(t, "LRU")
+		output := converse(t, s.handleRespConnection, c.input)
+		if output != c.output {
+			t.Errorf("%s: got %q, want %q", c.name, output, c.output)
+		}
+	}
+}
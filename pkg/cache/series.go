@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*seriesSample is one point of the time series: how the
+run looks after a given number of requests*/
+type seriesSample struct {
+	Requests   int64              `json:"requests"`
+	HitRate    float64            `json:"hit_rate"`
+	CostServed int64              `json:"cost_served"`
+	CostSaved  int64              `json:"cost_saved"`
+	Weights    map[string]float64 `json:"weights,omitempty"`
+}
+
+/*timeSeries samples expert weights, hit rate and cumulative
+cost every N requests, so weight changes can be plotted
+against the phases of a trace.  Files ending in .json get
+one JSON object per line, anything else gets CSV*/
+type timeSeries struct {
+	every   int64
+	file    *os.File
+	csv     *csv.Writer
+	json    *json.Encoder
+	experts []string
+}
+
+func newTimeSeries(filename string, every int) (*timeSeries, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	series := &timeSeries{every: int64(every), file: file}
+	if strings.HasSuffix(filename, ".json") {
+		series.json = json.NewEncoder(file)
+	} else {
+		series.csv = csv.NewWriter(file)
+	}
+	return series, nil
+}
+
+func (ts *timeSeries) due(requests int64) bool {
+	return ts.every > 0 && requests%ts.every == 0
+}
+
+func (ts *timeSeries) write(sample seriesSample) error {
+	if ts.json != nil {
+		return ts.json.Encode(sample)
+	}
+	if ts.experts == nil {
+		// the columns are fixed by whichever experts the first sample has
+		ts.experts = []string{}
+		for expert := range sample.Weights {
+			ts.experts = append(ts.experts, expert)
+		}
+		sort.Strings(ts.experts)
+		header := []string{"requests", "hit_rate", "cost_served", "cost_saved"}
+		for _, expert := range ts.experts {
+			header = append(header, "weight_"+expert)
+		}
+		ts.csv.Write(header)
+	}
+	row := []string{
+		strconv.FormatInt(sample.Requests, 10),
+		strconv.FormatFloat(sample.HitRate, 'f', 6, 64),
+		strconv.FormatInt(sample.CostServed, 10),
+		strconv.FormatInt(sample.CostSaved, 10),
+	}
+	for _, expert := range ts.experts {
+		row = append(row, strconv.FormatFloat(sample.Weights[expert], 'f', 6, 64))
+	}
+	ts.csv.Write(row)
+	ts.csv.Flush()
+	return ts.csv.Error()
+}
+
+func (ts *timeSeries) close() error {
+	if ts.csv != nil {
+		ts.csv.Flush()
+	}
+	return ts.file.Close()
+}
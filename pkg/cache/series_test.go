@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*sampleRun fetches a fixed sequence through a server sampling every
+two requests, returning what it wrote to the series file*/
+func sampleRun(t *testing.T, cacheType string, filename string) string {
+	conf := testConf(t, cacheType)
+	conf.SampleFile = filepath.Join(t.TempDir(), filename)
+	conf.SampleEvery = 2
+	s := startTestServer(t, conf)
+	// with room for two: miss, hit, miss, miss, miss, miss
+	converse(t, s.handleConnection, "mfetch,key1,key1,key2,key3,key1,key2\n")
+	converse(t, s.handleConnection, "fetch,key3\n")
+	err := s.series.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(conf.SampleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestSeriesCSV(t *testing.T) {
+	want := "requests,hit_rate,cost_served,cost_saved\n" +
+		"2,0.500000,10,10\n" +
+		"4,0.250000,60,10\n" +
+		"6,0.166667,90,10\n"
+	if got := sampleRun(t, "LRU", "series.csv"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSeriesJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(sampleRun(t, "LECAR", "series.json")), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d samples, want 3", len(lines))
+	}
+	for i, line := range lines {
+		sample := seriesSample{}
+		err := json.Unmarshal([]byte(line), &sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample.Requests != int64(2*(i+1)) {
+			t.Errorf("sample %d is at %d requests, want %d", i, sample.Requests, 2*(i+1))
+		}
+		total := 0.0
+		for _, weight := range sample.Weights {
+			total += weight
+		}
+		if len(sample.Weights) != 2 || math.Abs(total-1) > 1e-9 {
+			t.Errorf("sample %d has weights %v, want two summing to 1", i, sample.Weights)
+		}
+	}
+}
+
+func TestSeriesColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "series.csv")
+	series, err := newTimeSeries(filename, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.due(5) || !series.due(10) || series.due(15) || !series.due(20) {
+		t.Error("due should be every 10 requests")
+	}
+	// the first sample's experts fix the columns for the rest
+	series.write(seriesSample{Requests: 10, HitRate: 0.1, Weights: map[string]float64{"LRU": 0.25, "LFU": 0.75}})
+	series.write(seriesSample{Requests: 20, HitRate: 0.2, Weights: map[string]float64{"LRU": 0.5, "LCR": 0.5}})
+	series.close()
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rows = append(rows, scanner.Text())
+	}
+	want := []string{
+		"requests,hit_rate,cost_served,cost_saved,weight_LFU,weight_LRU",
+		"10,0.100000,0,0,0.750000,0.250000",
+		"20,0.200000,0,0,0.000000,0.500000",
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+	never := &timeSeries{}
+	if never.due(10) {
+		t.Error("a series without an interval is never due")
+	}
+}
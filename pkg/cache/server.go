@@ -1,26 +1,51 @@
 package cache
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/classes"
+	"github.com/evizitei/lcr-cache/pkg/dataset"
 )
 
 /*ServerConf holds the cmd flags and other
 config params for parameterizing the cache
 server*/
 type ServerConf struct {
-	LogFile   *string
-	DataFile  *string
-	CacheType *string
-	CacheSize int
-	Verbose   bool
+	LogFile       *string
+	DataFile      *string
+	CacheType     *string
+	CacheSize     int
+	Verbose       bool
+	Host          string
+	Port          int
+	Socket        string
+	DrainTimeout  time.Duration
+	ReportFile    string
+	AdminPort     int
+	SampleEvery   int
+	SampleFile    string
+	HTTPPort      int
+	MemcachedPort int
+	RespPort      int
+	BinaryPort    int
+	Classifier    *classes.Classifier
+	RecordFile    string
 }
 
 /*Entry is the thing stored in a cache, both
@@ -38,9 +63,139 @@ type Server struct {
 	dataset *map[string]Entry
 	logger  *log.Logger
 	cache   Cache
+	stats   *serverStats
+	series  *timeSeries
+	record  *recorder
+	// counts connections across every listener, for the recording
+	connections int64
+	// guards the cache, which is not safe for concurrent use
+	cacheLock sync.Mutex
+	closing   chan struct{}
+	inFlight  sync.WaitGroup
+}
+
+/*fetch consults the cache, falling back to the dataset on a miss
+and remembering the result.  The bool is true for a cache hit*/
+func (s *Server) fetch(key string, from origin) (Entry, bool, error) {
+	start := time.Now()
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if s.cache.KeyPresent(key) {
+		if s.config.Verbose {
+			s.logger.Println("Found in cache! ", key)
+		}
+		entry, err := s.cache.GetValue(key)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		s.stats.recordHit(entry.cost, len(entry.value), s.classify(key, entry), time.Since(start))
+		s.recordRequest(from, key, recordHit, entry.cost)
+		s.sample()
+		return entry, true, nil
+	}
+	entry, ok := (*s.dataset)[key]
+	if !ok {
+		s.recordRequest(from, key, recordNotFound, 0)
+		return Entry{}, false, errors.New("No Entry For Key: " + key)
+	}
+	s.cache.SetValue(key, entry)
+	s.stats.recordMiss(entry.cost, len(entry.value), s.classify(key, entry), time.Since(start))
+	s.recordRequest(from, key, recordMiss, entry.cost)
+	s.sample()
+	return entry, false, nil
+}
+
+/*classify names the key class a request is counted under,
+or nothing when no classifier is configured*/
+func (s *Server) classify(key string, entry Entry) string {
+	if s.config.Classifier == nil {
+		return ""
+	}
+	return s.config.Classifier.Class(key, entry.cost)
 }
 
-func (s *Server) handleConnection(c net.Conn) {
+/*recordRequest adds a request to the recording, if there is one,
+along with the value for a set.  The caller must hold the cache lock*/
+func (s *Server) recordRequest(from origin, key string, result string, cost int, value ...string) {
+	if s.record != nil {
+		s.record.record(from, key, result, cost, value...)
+	}
+}
+
+/*remove drops a key from the cache, if it's there*/
+func (s *Server) remove(key string, from origin) error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	s.recordRequest(from, key, recordWrite, 0)
+	return s.cache.DeleteValue(key)
+}
+
+/*store puts a value in the cache and the dataset behind it,
+keeping whatever cost the dataset already knows for the key*/
+func (s *Server) store(key string, value string, from origin) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	entry := (*s.dataset)[key]
+	entry.value = value
+	(*s.dataset)[key] = entry
+	// quoted, since reading CSV turns a \r\n inside a value into \n
+	s.recordRequest(from, key, recordWrite, entry.cost, strconv.Quote(value))
+	// the caches don't support replacing an entry in place, and
+	// KeyPresent would count this as a request for the key
+	s.cache.DeleteValue(key)
+	s.cache.SetValue(key, entry)
+}
+
+/*flush empties the cache by starting over with a fresh one, and
+zeroes the counters too when resetting*/
+func (s *Server) flush(from origin, resetting bool) error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	cache, err := NewCache(*s.config.CacheType, s.config.CacheSize)
+	if err != nil {
+		return err
+	}
+	s.cache = cache
+	if resetting {
+		s.stats.reset()
+	}
+	s.recordRequest(from, "", recordWrite, 0)
+	return nil
+}
+
+/*Reset empties the cache and zeroes the counters, so a new
+experiment can start without restarting the server*/
+func (s *Server) Reset() error {
+	return s.flush(origin{command: "reset"}, true)
+}
+
+/*sample adds a point to the time series when one is due.
+The caller must hold the cache lock*/
+func (s *Server) sample() {
+	if s.series == nil {
+		return
+	}
+	s.stats.lock.Lock()
+	sample := seriesSample{
+		Requests:   s.stats.requests,
+		HitRate:    float64(s.stats.hits) / float64(s.stats.requests),
+		CostServed: s.stats.costServed,
+		CostSaved:  s.stats.costSaved,
+	}
+	s.stats.lock.Unlock()
+	if !s.series.due(sample.Requests) {
+		return
+	}
+	if weighted, ok := s.cache.(WeightedCache); ok {
+		sample.Weights = weighted.Weights()
+	}
+	err := s.series.write(sample)
+	if err != nil {
+		s.logger.Println("ERROR writing time series: ", err)
+	}
+}
+
+func (s *Server) handleConnection(c net.Conn, conn int64) {
 	buf := make([]byte, 1024)
 	_, err := c.Read(buf)
 	if err != nil {
@@ -50,36 +205,54 @@ func (s *Server) handleConnection(c net.Conn) {
 		return
 	}
 	messageValue := string(bytes.Trim(buf, "\x00"))
+	if strings.HasPrefix(messageValue, "mfetch") && !strings.Contains(messageValue, "\n") {
+		// a batch can outgrow the buffer, so it runs on to its newline,
+		// or to the end of what the client sends when it leaves that off
+		rest, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil && err != io.EOF {
+			s.logger.Println("Conn error: ", err.Error())
+			c.Write([]byte("Read Failure, check logs..."))
+			c.Close()
+			return
+		}
+		messageValue = messageValue + rest
+	}
 	messageParts := strings.Split(messageValue, ",")
-	command := messageParts[0]
+	command := strings.TrimSpace(messageParts[0])
 	if command == "fetch" {
 		fetchKey := strings.TrimSpace(strings.Replace(messageParts[1], "\n", "", -1))
 		if s.config.Verbose {
 			s.logger.Println("Fetching ", fetchKey)
 		}
-		if s.cache.KeyPresent(fetchKey) {
-			if s.config.Verbose {
-				s.logger.Println("Found in cache! ", fetchKey)
-			}
-			entry, err := s.cache.GetValue(fetchKey)
-			if err != nil {
-				s.logger.Println("ERROR IN CACHE: ", err)
-				return
-			}
+		entry, hit, err := s.fetch(fetchKey, origin{conn, "fetch"})
+		if err != nil {
+			s.logger.Println("Fetch failed for |"+fetchKey+"|: ", err)
+			c.Write([]byte(err.Error() + "\n"))
+		} else if hit {
 			c.Write([]byte("VALUE:" + entry.value + "\n"))
 			c.Write([]byte("COST:0\n"))
-			c.Close()
-			return
-		}
-		entry, ok := (*s.dataset)[fetchKey]
-		if !ok {
-			s.logger.Println("No Entry for |" + fetchKey + "|")
-			c.Write([]byte("No Entry For Key: " + fetchKey + "\n"))
+			c.Write([]byte("SAVED:" + strconv.Itoa(entry.cost) + "\n"))
+			c.Write([]byte("HIT:true\n"))
 		} else {
 			c.Write([]byte("VALUE:" + entry.value + "\n"))
 			c.Write([]byte("COST:" + strconv.Itoa(entry.cost) + "\n"))
-			s.cache.SetValue(fetchKey, entry)
+			c.Write([]byte("SAVED:0\n"))
+			c.Write([]byte("HIT:false\n"))
+		}
+		c.Close()
+	} else if command == "mfetch" {
+		response := ""
+		for _, part := range messageParts[1:] {
+			fetchKey := strings.TrimSpace(part)
+			if fetchKey == "" {
+				continue
+			}
+			response = response + s.batchResult(fetchKey, origin{conn, "mfetch"})
 		}
+		c.Write([]byte(response))
+		c.Close()
+	} else if command == "stats" {
+		c.Write([]byte(s.Report().Summary()))
 		c.Close()
 	} else {
 		s.logger.Println("No such command: ", command)
@@ -88,22 +261,253 @@ func (s *Server) handleConnection(c net.Conn) {
 	}
 }
 
+/*batchResult fetches one key of an mfetch, describing it in a
+KEY/VALUE/COST/SAVED/HIT block (or KEY/ERROR when it can't be found)*/
+func (s *Server) batchResult(key string, from origin) string {
+	if s.config.Verbose {
+		s.logger.Println("Fetching ", key)
+	}
+	entry, hit, err := s.fetch(key, from)
+	if err != nil {
+		s.logger.Println("Fetch failed for |"+key+"|: ", err)
+		return "KEY:" + key + "\nERROR:" + err.Error() + "\n"
+	}
+	cost, saved := entry.cost, 0
+	if hit {
+		cost, saved = 0, entry.cost
+	}
+	return "KEY:" + key + "\n" +
+		"VALUE:" + entry.value + "\n" +
+		"COST:" + strconv.Itoa(cost) + "\n" +
+		"SAVED:" + strconv.Itoa(saved) + "\n" +
+		"HIT:" + strconv.FormatBool(hit) + "\n"
+}
+
+/*listenAddress prefers a unix socket to host/port when one is configured*/
+func (s *Server) listenAddress() (string, string) {
+	if s.config.Socket != "" {
+		return "unix", s.config.Socket
+	}
+	return "tcp", net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+}
+
 /*Listen is how you kick off a serve
 loop to wait for incoing connections*/
 func (s *Server) Listen() {
-	s.logger.Println("Starting cache server...")
-	ln, err := net.Listen("tcp", ":1234")
+	network, address := s.listenAddress()
+	s.logger.Println("Starting cache server on " + network + " " + address + "...")
+	if network == "unix" {
+		// clear out a socket file left behind by a previous run, but
+		// leave anything else at that path for net.Listen to refuse
+		if info, err := os.Lstat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	ln, err := net.Listen(network, address)
 	if err != nil {
 		s.logger.Fatalln("Could not start server: ", err.Error())
 		os.Exit(-1)
 	}
+	var admin *http.Server
+	if s.config.AdminPort > 0 {
+		admin = s.startAdmin()
+	}
+	var front *http.Server
+	if s.config.HTTPPort > 0 {
+		front = s.startHTTP()
+	}
+	listeners := []net.Listener{ln}
+	if s.config.MemcachedPort > 0 {
+		mln := s.listenExtra("memcached protocol", s.config.MemcachedPort)
+		listeners = append(listeners, mln)
+		go s.acceptLoop(mln, s.handleMemcachedConnection)
+	}
+	if s.config.RespPort > 0 {
+		rln := s.listenExtra("redis protocol", s.config.RespPort)
+		listeners = append(listeners, rln)
+		go s.acceptLoop(rln, s.handleRespConnection)
+	}
+	if s.config.BinaryPort > 0 {
+		bln := s.listenExtra("binary protocol", s.config.BinaryPort)
+		listeners = append(listeners, bln)
+		go s.acceptLoop(bln, s.handleBinaryConnection)
+	}
+	go s.awaitSignal(listeners)
+	s.acceptLoop(ln, s.handleConnection)
+	if front != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.DrainTimeout)
+		front.Shutdown(ctx)
+		cancel()
+	}
+	s.drain()
+	if admin != nil {
+		admin.Close()
+	}
+	s.finalReport()
+}
+
+/*listenExtra opens a tcp listener for one of the optional
+protocols or HTTP servers, giving up on the server if it can't*/
+func (s *Server) listenExtra(name string, port int) net.Listener {
+	address := net.JoinHostPort(s.config.Host, strconv.Itoa(port))
+	s.logger.Println("Serving " + name + " on " + address)
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		s.logger.Fatalln("Could not start "+name+" listener: ", err.Error())
+	}
+	return ln
+}
+
+/*acceptLoop hands each connection to the handler until shutdown*/
+func (s *Server) acceptLoop(ln net.Listener, handler func(net.Conn, int64)) {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if s.isClosing() {
+				return
+			}
 			s.logger.Println("WARNING: Failed to handle request: ", err.Error())
 			continue
 		}
-		go s.handleConnection(conn)
+		s.inFlight.Add(1)
+		id := s.nextConn()
+		go func() {
+			defer s.inFlight.Done()
+			handler(conn, id)
+		}()
+	}
+}
+
+/*nextConn numbers a new connection*/
+func (s *Server) nextConn() int64 {
+	return atomic.AddInt64(&s.connections, 1)
+}
+
+/*startAdmin serves prometheus metrics over http on the admin port*/
+func (s *Server) startAdmin() *http.Server {
+	ln := s.listenExtra("metrics", s.config.AdminPort)
+	admin := &http.Server{Handler: s.adminHandler()}
+	go func() {
+		err := admin.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Println("ERROR serving metrics: ", err)
+		}
+	}()
+	return admin
+}
+
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.writeMetrics(w)
+	})
+	return mux
+}
+
+/*awaitSignal stops the accept loops on SIGINT or SIGTERM*/
+func (s *Server) awaitSignal(listeners []net.Listener) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	s.logger.Println("Received " + sig.String() + ", shutting down...")
+	close(s.closing)
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
+func (s *Server) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+/*drain waits for in-flight connections, but not forever*/
+func (s *Server) drain() {
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.logger.Println("All connections drained")
+	case <-time.After(s.config.DrainTimeout):
+		s.logger.Println("WARNING: Gave up on in-flight connections after ", s.config.DrainTimeout)
+	}
+}
+
+/*Report summarizes everything the server has done so far*/
+func (s *Server) Report() *Report {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	s.stats.lock.Lock()
+	defer s.stats.lock.Unlock()
+	report := &Report{
+		CacheType:  *s.config.CacheType,
+		CacheSize:  s.config.CacheSize,
+		Requests:   s.stats.requests,
+		Hits:       s.stats.hits,
+		Misses:     s.stats.misses,
+		CostServed: s.stats.costServed,
+		CostSaved:  s.stats.costSaved,
+		Bytes:      s.stats.bytes,
+		BytesHit:   s.stats.bytesHit,
+		Evictions:  s.cache.Evictions(),
+	}
+	if len(s.stats.classes) > 0 {
+		report.Classes = map[string]*ClassReport{}
+		for name, class := range s.stats.classes {
+			counters := *class
+			report.Classes[name] = &counters
+		}
+	}
+	if weighted, ok := s.cache.(WeightedCache); ok {
+		report.Weights = weighted.Weights()
+	}
+	return report
+}
+
+func (s *Server) finalReport() {
+	report := s.Report()
+	s.logger.Println("FINAL REPORT")
+	s.logger.Println("CACHE: ", report.CacheType, report.CacheSize)
+	s.logger.Println("REQUESTS: ", report.Requests)
+	s.logger.Println("HITS: ", report.Hits)
+	s.logger.Println("MISSES: ", report.Misses)
+	s.logger.Println("HIT RATE: ", report.HitRate())
+	s.logger.Println("COST SERVED: ", report.CostServed)
+	s.logger.Println("COST SAVED: ", report.CostSaved)
+	s.logger.Println("BYTE HIT RATE: ", report.ByteHitRate())
+	s.logger.Println("COST HIT RATE: ", report.CostHitRate())
+	if report.Weights != nil {
+		s.logger.Println("WEIGHTS: ", report.Weights)
+	}
+	s.logger.Println("EVICTIONS: ", report.Evictions)
+	for _, name := range report.ClassNames() {
+		class := report.Classes[name]
+		s.logger.Println("CLASS: ", name, "REQUESTS:", class.Requests, "HITS:", class.Hits, "MISSES:", class.Misses,
+			"HIT RATE:", class.HitRate(), "COST SERVED:", class.CostServed, "COST SAVED:", class.CostSaved,
+			"COST HIT RATE:", class.CostHitRate())
+	}
+	if s.config.ReportFile != "" {
+		err := report.writeJSON(s.config.ReportFile)
+		if err != nil {
+			s.logger.Println("ERROR writing report file: ", err)
+		}
+	}
+	if s.series != nil {
+		s.series.close()
+	}
+	if s.record != nil {
+		err := s.record.close()
+		if err != nil {
+			s.logger.Println("ERROR writing recording: ", err)
+		}
 	}
 }
 
@@ -119,30 +523,17 @@ func buildLogger(logfile *string) *log.Logger {
 }
 
 func loadDataset(datafile *string) *map[string]Entry {
-	dataMap := make(map[string]Entry)
-	dFile, err := os.OpenFile(*datafile, os.O_RDONLY, 0666)
+	records, err := dataset.Read(*datafile)
 	if err != nil {
-		fmt.Println("ERROR opening dataset: ", err)
+		fmt.Println("ERROR reading dataset: ", err)
 		os.Exit(-1)
 	}
-	reader := csv.NewReader(dFile)
-	for {
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Println("Error reading csv file: ", err)
-		}
-		cost, err := strconv.Atoi(row[2])
-		if err != nil {
-			fmt.Println("Error reading cost value from file: ", err)
+	dataMap := make(map[string]Entry, len(records))
+	for key, record := range records {
+		dataMap[key] = Entry{
+			value: record.Value,
+			cost:  record.Cost,
 		}
-		entry := Entry{
-			value: row[1],
-			cost:  cost,
-		}
-		dataMap[row[0]] = entry
 	}
 	return &dataMap
 }
@@ -155,10 +546,28 @@ func NewServer(conf *ServerConf) *Server {
 	if err != nil {
 		logger.Fatalln("Error while constructing cache: ", err)
 	}
+	var series *timeSeries
+	if conf.SampleFile != "" {
+		series, err = newTimeSeries(conf.SampleFile, conf.SampleEvery)
+		if err != nil {
+			logger.Fatalln("Error while opening time series file: ", err)
+		}
+	}
+	var record *recorder
+	if conf.RecordFile != "" {
+		record, err = newRecorder(conf.RecordFile)
+		if err != nil {
+			logger.Fatalln("Error while opening recording file: ", err)
+		}
+	}
 	return &Server{
 		config:  conf,
 		dataset: loadDataset(conf.DataFile),
 		logger:  logger,
 		cache:   cache,
+		stats:   newServerStats(),
+		series:  series,
+		record:  record,
+		closing: make(chan struct{}),
 	}
 }
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+var testRecords = []dataset.Record{
+	{Key: "key1", Value: "val1", Cost: 10},
+	{Key: "key2", Value: "val2", Cost: 20},
+	{Key: "key3", Value: "val3", Cost: 30},
+}
+
+/*newTestServer starts a server over testRecords with a cache of
+two entries, logging nowhere*/
+func newTestServer(t *testing.T, cacheType string) *Server {
+	return startTestServer(t, testConf(t, cacheType))
+}
+
+func startTestServer(t *testing.T, conf *ServerConf) *Server {
+	s := NewServer(conf)
+	s.logger.SetOutput(ioutil.Discard)
+	return s
+}
+
+/*testConf writes testRecords out for a server to load*/
+func testConf(t *testing.T, cacheType string) *ServerConf {
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "data.csv")
+	file, err := os.Create(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dataset.Write(file, testRecords)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(dir, "server.log")
+	return &ServerConf{LogFile: &logFile, DataFile: &dataFile, CacheType: &cacheType, CacheSize: 2}
+}
+
+/*converse hands one tcp connection to the handler, sends it the
+input, and returns everything it replies until it hangs up*/
+func converse(t *testing.T, handler func(net.Conn, int64), input string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			handler(c, 1)
+		}
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go func() {
+		c.Write([]byte(input))
+		c.(*net.TCPConn).CloseWrite()
+	}()
+	reply, err := ioutil.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestFetchProtocol(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"fetch", "fetch,key1\n", "VALUE:val1\nCOST:10\nSAVED:0\nHIT:false\n"},
+		{"fetch without a newline", "fetch,key2", "VALUE:val2\nCOST:20\nSAVED:0\nHIT:false\n"},
+		{"fetch unknown key", "fetch,nope\n", "No Entry For Key: nope\n"},
+		{"mfetch", "mfetch,key1,key1,nope\n",
+			"KEY:key1\nVALUE:val1\nCOST:10\nSAVED:0\nHIT:false\n" +
+				"KEY:key1\nVALUE:val1\nCOST:0\nSAVED:10\nHIT:true\n" +
+				"KEY:nope\nERROR:No Entry For Key: nope\n"},
+		{"mfetch without a newline", "mfetch,key1,key2",
+			"KEY:key1\nVALUE:val1\nCOST:10\nSAVED:0\nHIT:false\n" +
+				"KEY:key2\nVALUE:val2\nCOST:20\nSAVED:0\nHIT:false\n"},
+		{"mfetch past the buffer", "mfetch," + strings.Repeat("key3,", 300) + "\n",
+			"KEY:key3\nVALUE:val3\nCOST:30\nSAVED:0\nHIT:false\n" +
+				strings.Repeat("KEY:key3\nVALUE:val3\nCOST:0\nSAVED:30\nHIT:true\n", 299)},
+		{"unknown command", "frob,key1\n", "Bad Command"},
+	}
+	for _, c := range cases {
+		s := newTestServer(t, "LRU")
+		output := converse(t, s.handleConnection, c.input)
+		if output != c.output {
+			t.Errorf("%s: got %q, want %q", c.name, output, c.output)
+		}
+	}
+}
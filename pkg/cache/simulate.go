@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+/*Simulate replays trace files straight against the cache,
+without any networking, using the same accounting as a
+served fetch.  Deletes drop the key from the cache, and
+every other request is a fetch (a set leaves the key
+cached just like a miss does), except in a recording: its
+sets store their value uncounted and its flushes and resets
+empty the cache, just as they did on the server.  It
+finishes with the same final report the server writes on
+shutdown*/
+func (s *Server) Simulate(keyFiles []string, format string) error {
+	s.logger.Println("Simulating " + *s.config.CacheType + " cache...")
+	err := trace.EachWithEvents(keyFiles, format, func(request trace.Request) error {
+		if request.Op == trace.Delete {
+			s.remove(request.Key, origin{})
+			return nil
+		} else if request.Op == trace.Flush || request.Op == trace.Reset {
+			return s.flush(origin{}, request.Op == trace.Reset)
+		} else if request.Op == trace.Set && format == "recording" {
+			s.store(request.Key, request.Value, origin{})
+			return nil
+		}
+		_, _, err := s.fetch(request.Key, origin{})
+		if err != nil {
+			s.logger.Println("Fetch failed for |"+request.Key+"|: ", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.finalReport()
+	return nil
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	cases := []struct {
+		cacheType string
+		format    string
+		trace     string
+		hits      int64
+		misses    int64
+		costSaved int64
+	}{
+		// key3 pushes out key2 under LRU but key1 under FIFO
+		{"LRU", "keys", "key1\nkey2\nkey1\nkey3\nkey2\nkey1\n", 1, 5, 10},
+		{"FIFO", "keys", "key1\nkey2\nkey1\nkey3\nkey2\nkey1\n", 2, 4, 30},
+		// the delete costs the next get its hit, and a set is a fetch
+		{"LRU", "twitter", "0,key1,4,4,1,get,0\n1,key1,4,4,1,delete,0\n2,key1,4,4,1,get,0\n3,key1,4,4,1,set,0\n", 1, 2, 10},
+	}
+	for _, c := range cases {
+		traceFile := filepath.Join(t.TempDir(), "trace")
+		err := ioutil.WriteFile(traceFile, []byte(c.trace), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newTestServer(t, c.cacheType)
+		err = s.Simulate([]string{traceFile}, c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := s.Report()
+		if report.Hits != c.hits || report.Misses != c.misses || report.CostSaved != c.costSaved {
+			t.Errorf("%s over %s: got %d hits, %d misses and %d saved, want %d, %d and %d", c.cacheType, c.format,
+				report.Hits, report.Misses, report.CostSaved, c.hits, c.misses, c.costSaved)
+		}
+	}
+}
+
+func TestSimulateBadTrace(t *testing.T) {
+	s := newTestServer(t, "LRU")
+	err := s.Simulate([]string{filepath.Join(t.TempDir(), "missing")}, "keys")
+	if err == nil {
+		t.Error("simulating a missing trace should fail")
+	}
+}
@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*latencyBuckets are the upper bounds, in seconds, of the latency histogram*/
+var latencyBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01}
+
+/*latencyHistogram counts how long requests took in
+cumulative buckets, the way prometheus expects them*/
+type latencyHistogram struct {
+	buckets []int64
+	count   int64
+	sum     float64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{buckets: make([]int64, len(latencyBuckets))}
+}
+
+func (h *latencyHistogram) observe(latency time.Duration) {
+	seconds := latency.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (h *latencyHistogram) writeMetrics(w io.Writer, name string, path string) {
+	for i, bound := range latencyBuckets {
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		fmt.Fprintf(w, "%s_bucket{path=\"%s\",le=\"%s\"} %d\n", name, path, le, h.buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{path=\"%s\",le=\"+Inf\"} %d\n", name, path, h.count)
+	fmt.Fprintf(w, "%s_sum{path=\"%s\"} %g\n", name, path, h.sum)
+	fmt.Fprintf(w, "%s_count{path=\"%s\"} %d\n", name, path, h.count)
+}
+
+/*serverStats accumulates what the server has done with
+every fetch since it started.  Connections are handled
+concurrently so all access goes through the lock*/
+type serverStats struct {
+	lock        sync.Mutex
+	requests    int64
+	hits        int64
+	misses      int64
+	costServed  int64
+	costSaved   int64
+	bytes       int64
+	bytesHit    int64
+	classes     map[string]*ClassReport
+	hitLatency  *latencyHistogram
+	missLatency *latencyHistogram
+}
+
+func newServerStats() *serverStats {
+	return &serverStats{
+		hitLatency:  newLatencyHistogram(),
+		missLatency: newLatencyHistogram(),
+		classes:     map[string]*ClassReport{},
+	}
+}
+
+func (st *serverStats) reset() {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.requests = 0
+	st.hits = 0
+	st.misses = 0
+	st.costServed = 0
+	st.costSaved = 0
+	st.bytes = 0
+	st.bytesHit = 0
+	st.classes = map[string]*ClassReport{}
+	st.hitLatency = newLatencyHistogram()
+	st.missLatency = newLatencyHistogram()
+}
+
+/*class finds the counters for a key class, nil when the
+server isn't breaking requests down by class*/
+func (st *serverStats) class(name string) *ClassReport {
+	if name == "" {
+		return nil
+	}
+	class, ok := st.classes[name]
+	if !ok {
+		class = &ClassReport{}
+		st.classes[name] = class
+	}
+	return class
+}
+
+func (st *serverStats) recordHit(cost int, size int, class string, latency time.Duration) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.requests++
+	st.hits++
+	st.costSaved += int64(cost)
+	st.bytes += int64(size)
+	st.bytesHit += int64(size)
+	if counters := st.class(class); counters != nil {
+		counters.Requests++
+		counters.Hits++
+		counters.CostSaved += int64(cost)
+	}
+	st.hitLatency.observe(latency)
+}
+
+func (st *serverStats) recordMiss(cost int, size int, class string, latency time.Duration) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.requests++
+	st.misses++
+	st.costServed += int64(cost)
+	st.bytes += int64(size)
+	if counters := st.class(class); counters != nil {
+		counters.Requests++
+		counters.Misses++
+		counters.CostServed += int64(cost)
+	}
+	st.missLatency.observe(latency)
+}
+
+/*Report is a summary of a server run, written to the log
+and optionally to a JSON file when the server shuts down*/
+type Report struct {
+	CacheType  string                  `json:"cache_type"`
+	CacheSize  int                     `json:"cache_size"`
+	Requests   int64                   `json:"requests"`
+	Hits       int64                   `json:"hits"`
+	Misses     int64                   `json:"misses"`
+	CostServed int64                   `json:"cost_served"`
+	CostSaved  int64                   `json:"cost_saved"`
+	Bytes      int64                   `json:"bytes"`
+	BytesHit   int64                   `json:"bytes_hit"`
+	Weights    map[string]float64      `json:"weights,omitempty"`
+	Evictions  map[string]int          `json:"evictions"`
+	Classes    map[string]*ClassReport `json:"classes,omitempty"`
+}
+
+/*ClassReport is how requests for one class of keys did*/
+type ClassReport struct {
+	Requests   int64 `json:"requests"`
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	CostServed int64 `json:"cost_served"`
+	CostSaved  int64 `json:"cost_saved"`
+}
+
+/*HitRate is the fraction of the class's requests served from the cache*/
+func (c *ClassReport) HitRate() float64 {
+	if c.Requests == 0 {
+		return 0.0
+	}
+	return float64(c.Hits) / float64(c.Requests)
+}
+
+/*CostHitRate is the fraction of the class's recomputation cost the cache saved*/
+func (c *ClassReport) CostHitRate() float64 {
+	total := c.CostServed + c.CostSaved
+	if total == 0 {
+		return 0.0
+	}
+	return float64(c.CostSaved) / float64(total)
+}
+
+/*HitRate is the fraction of requests served from the cache*/
+func (r *Report) HitRate() float64 {
+	if r.Requests == 0 {
+		return 0.0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+/*ByteHitRate is the fraction of value bytes served from the cache*/
+func (r *Report) ByteHitRate() float64 {
+	if r.Bytes == 0 {
+		return 0.0
+	}
+	return float64(r.BytesHit) / float64(r.Bytes)
+}
+
+/*CostHitRate is the fraction of the total recomputation cost
+the cache saved, what it would have cost with no cache at all*/
+func (r *Report) CostHitRate() float64 {
+	total := r.CostServed + r.CostSaved
+	if total == 0 {
+		return 0.0
+	}
+	return float64(r.CostSaved) / float64(total)
+}
+
+/*TotalEvictions sums the evictions made by every expert*/
+func (r *Report) TotalEvictions() int {
+	total := 0
+	for _, count := range r.Evictions {
+		total += count
+	}
+	return total
+}
+
+/*Summary renders the report as KEY:value lines, the same
+shape as the response to a fetch command*/
+func (r *Report) Summary() string {
+	summary := "CACHE:" + r.CacheType + "\n" +
+		"SIZE:" + strconv.Itoa(r.CacheSize) + "\n" +
+		"REQUESTS:" + strconv.FormatInt(r.Requests, 10) + "\n" +
+		"HITS:" + strconv.FormatInt(r.Hits, 10) + "\n" +
+		"MISSES:" + strconv.FormatInt(r.Misses, 10) + "\n" +
+		"EVICTIONS:" + strconv.Itoa(r.TotalEvictions()) + "\n" +
+		"COST_SERVED:" + strconv.FormatInt(r.CostServed, 10) + "\n" +
+		"COST_SAVED:" + strconv.FormatInt(r.CostSaved, 10) + "\n" +
+		"BYTES:" + strconv.FormatInt(r.Bytes, 10) + "\n" +
+		"BYTES_HIT:" + strconv.FormatInt(r.BytesHit, 10) + "\n"
+	for _, expert := range sortedExperts(r.Evictions) {
+		summary += "EVICTIONS_" + expert + ":" + strconv.Itoa(r.Evictions[expert]) + "\n"
+	}
+	for _, expert := range sortedExperts(r.Evictions) {
+		if weight, ok := r.Weights[expert]; ok {
+			summary += "WEIGHT_" + expert + ":" + strconv.FormatFloat(weight, 'f', 6, 64) + "\n"
+		}
+	}
+	for _, name := range r.ClassNames() {
+		class := r.Classes[name]
+		summary += "CLASS_" + name + ":" +
+			"requests=" + strconv.FormatInt(class.Requests, 10) +
+			" hits=" + strconv.FormatInt(class.Hits, 10) +
+			" misses=" + strconv.FormatInt(class.Misses, 10) +
+			" cost_served=" + strconv.FormatInt(class.CostServed, 10) +
+			" cost_saved=" + strconv.FormatInt(class.CostSaved, 10) + "\n"
+	}
+	return summary
+}
+
+/*ClassNames lists the report's key classes in order*/
+func (r *Report) ClassNames() []string {
+	names := make([]string, 0, len(r.Classes))
+	for name := range r.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedExperts(evictions map[string]int) []string {
+	experts := make([]string, 0, len(evictions))
+	for expert := range evictions {
+		experts = append(experts, expert)
+	}
+	sort.Strings(experts)
+	return experts
+}
+
+/*writeMetrics renders the counters in the prometheus text format*/
+func (s *Server) writeMetrics(w io.Writer) {
+	report := s.Report()
+	fmt.Fprintln(w, "# TYPE lcr_cache_requests_total counter")
+	fmt.Fprintf(w, "lcr_cache_requests_total %d\n", report.Requests)
+	fmt.Fprintln(w, "# TYPE lcr_cache_hits_total counter")
+	fmt.Fprintf(w, "lcr_cache_hits_total %d\n", report.Hits)
+	fmt.Fprintln(w, "# TYPE lcr_cache_misses_total counter")
+	fmt.Fprintf(w, "lcr_cache_misses_total %d\n", report.Misses)
+	fmt.Fprintln(w, "# TYPE lcr_cache_cost_served_total counter")
+	fmt.Fprintf(w, "lcr_cache_cost_served_total %d\n", report.CostServed)
+	fmt.Fprintln(w, "# TYPE lcr_cache_cost_saved_total counter")
+	fmt.Fprintf(w, "lcr_cache_cost_saved_total %d\n", report.CostSaved)
+	fmt.Fprintln(w, "# TYPE lcr_cache_bytes_total counter")
+	fmt.Fprintf(w, "lcr_cache_bytes_total %d\n", report.Bytes)
+	fmt.Fprintln(w, "# TYPE lcr_cache_bytes_hit_total counter")
+	fmt.Fprintf(w, "lcr_cache_bytes_hit_total %d\n", report.BytesHit)
+	fmt.Fprintln(w, "# TYPE lcr_cache_evictions_total counter")
+	for _, expert := range sortedExperts(report.Evictions) {
+		fmt.Fprintf(w, "lcr_cache_evictions_total{expert=\"%s\"} %d\n", expert, report.Evictions[expert])
+	}
+	if report.Weights != nil {
+		fmt.Fprintln(w, "# TYPE lcr_cache_expert_weight gauge")
+		for _, expert := range sortedExperts(report.Evictions) {
+			fmt.Fprintf(w, "lcr_cache_expert_weight{expert=\"%s\"} %g\n", expert, report.Weights[expert])
+		}
+	}
+	if len(report.Classes) > 0 {
+		fmt.Fprintln(w, "# TYPE lcr_cache_class_requests_total counter")
+		for _, name := range report.ClassNames() {
+			fmt.Fprintf(w, "lcr_cache_class_requests_total{class=\"%s\"} %d\n", name, report.Classes[name].Requests)
+		}
+		fmt.Fprintln(w, "# TYPE lcr_cache_class_hits_total counter")
+		for _, name := range report.ClassNames() {
+			fmt.Fprintf(w, "lcr_cache_class_hits_total{class=\"%s\"} %d\n", name, report.Classes[name].Hits)
+		}
+		fmt.Fprintln(w, "# TYPE lcr_cache_class_cost_served_total counter")
+		for _, name := range report.ClassNames() {
+			fmt.Fprintf(w, "lcr_cache_class_cost_served_total{class=\"%s\"} %d\n", name, report.Classes[name].CostServed)
+		}
+		fmt.Fprintln(w, "# TYPE lcr_cache_class_cost_saved_total counter")
+		for _, name := range report.ClassNames() {
+			fmt.Fprintf(w, "lcr_cache_class_cost_saved_total{class=\"%s\"} %d\n", name, report.Classes[name].CostSaved)
+		}
+	}
+	s.stats.lock.Lock()
+	defer s.stats.lock.Unlock()
+	fmt.Fprintln(w, "# TYPE lcr_cache_request_seconds histogram")
+	s.stats.hitLatency.writeMetrics(w, "lcr_cache_request_seconds", "hit")
+	s.stats.missLatency.writeMetrics(w, "lcr_cache_request_seconds", "miss")
+}
+
+func (r *Report) writeJSON(filename string) error {
+	body, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, body, 0666)
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/classes"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for _, latency := range []time.Duration{5 * time.Microsecond, 30 * time.Microsecond, 10 * time.Millisecond, time.Second} {
+		h.observe(latency)
+	}
+	var buf bytes.Buffer
+	h.writeMetrics(&buf, "m", "hit")
+	want := `m_bucket{path="hit",le="1e-05"} 1
+m_bucket{path="hit",le="2.5e-05"} 1
+m_bucket{path="hit",le="5e-05"} 2
+m_bucket{path="hit",le="0.0001"} 2
+m_bucket{path="hit",le="0.00025"} 2
+m_bucket{path="hit",le="0.0005"} 2
+m_bucket{path="hit",le="0.001"} 2
+m_bucket{path="hit",le="0.0025"} 2
+m_bucket{path="hit",le="0.005"} 2
+m_bucket{path="hit",le="0.01"} 3
+m_bucket{path="hit",le="+Inf"} 4
+m_sum{path="hit"} 1.010035
+m_count{path="hit"} 4
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+/*scrape fetches /metrics from the server's admin handler and
+splits it into metric lines and their values*/
+func scrape(t *testing.T, s *Server) map[string]string {
+	admin := httptest.NewServer(s.adminHandler())
+	defer admin.Close()
+	resp, err := http.Get(admin.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("got status %d with content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		metrics[fields[0]] = fields[1]
+	}
+	return metrics
+}
+
+func TestMetrics(t *testing.T) {
+	conf := testConf(t, "LRU")
+	classifier, err := classes.New("cost", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Classifier = classifier
+	s := startTestServer(t, conf)
+	// with room for two, key3 pushes key1 out
+	converse(t, s.handleConnection, "mfetch,key1,key1,key2,key3\n")
+	metrics := scrape(t, s)
+	want := map[string]string{
+		"lcr_cache_requests_total":                                "4",
+		"lcr_cache_hits_total":                                    "1",
+		"lcr_cache_misses_total":                                  "3",
+		"lcr_cache_cost_served_total":                             "60",
+		"lcr_cache_cost_saved_total":                              "10",
+		"lcr_cache_bytes_total":                                   "16",
+		"lcr_cache_bytes_hit_total":                               "4",
+		`lcr_cache_class_requests_total{class="cost_1e1"}`:        "4",
+		`lcr_cache_class_hits_total{class="cost_1e1"}`:            "1",
+		`lcr_cache_class_cost_served_total{class="cost_1e1"}`:     "60",
+		`lcr_cache_request_seconds_bucket{path="hit",le="+Inf"}`:  "1",
+		`lcr_cache_request_seconds_count{path="hit"}`:             "1",
+		`lcr_cache_request_seconds_bucket{path="miss",le="+Inf"}`: "3",
+		`lcr_cache_request_seconds_count{path="miss"}`:            "3",
+	}
+	for name, value := range want {
+		if metrics[name] != value {
+			t.Errorf("%s is %q, want %q", name, metrics[name], value)
+		}
+	}
+	evictions := 0
+	for name, value := range metrics {
+		if strings.HasPrefix(name, "lcr_cache_evictions_total{expert=") {
+			evictions++
+			if value != "1" {
+				t.Errorf("%s is %s, want 1", name, value)
+			}
+		}
+	}
+	if evictions != 1 {
+		t.Errorf("got %d eviction series, want one for LRU", evictions)
+	}
+	buckets := 0
+	for name := range metrics {
+		if strings.HasPrefix(name, `lcr_cache_request_seconds_bucket{path="miss"`) {
+			buckets++
+		}
+	}
+	if buckets != len(latencyBuckets)+1 {
+		t.Errorf("got %d miss buckets, want %d", buckets, len(latencyBuckets)+1)
+	}
+}
@@ -0,0 +1,137 @@
+package classes
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+/*Other is the class for keys a mapping file doesn't mention*/
+const Other = "other"
+
+/*Classifier sorts requests into key classes, so results can be
+broken down by population (the frequent, costly and scan sets of
+a generated trace, say) instead of only in aggregate.  Classes
+come from any combination of:
+
+	a mapping file   CSV of key,class
+	"cost"           the decade of the key's cost: cost_1e3, cost_1e4...
+	"frequency"      the decade of how often the trace requests the key: freq_1e0, freq_1e1...
+
+and combined classes are joined with a slash, like cost_1e5/freq_1e2*/
+type Classifier struct {
+	parts     []string
+	mapping   map[string]string
+	frequency map[string]int
+}
+
+/*New builds a classifier from a comma separated spec.  Frequency
+classes need the traces that are about to be replayed, and the
+format they're in*/
+func New(spec string, traces []string, format string) (*Classifier, error) {
+	c := &Classifier{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "cost" {
+			c.parts = append(c.parts, part)
+		} else if part == "frequency" {
+			if len(traces) == 0 {
+				return nil, errors.New("Frequency classes need a trace to count keys in")
+			}
+			for _, filename := range traces {
+				if filename == "-" {
+					return nil, errors.New("Frequency classes read the trace twice, which stdin can't do")
+				}
+			}
+			frequency, err := countKeys(traces, format)
+			if err != nil {
+				return nil, err
+			}
+			c.frequency = frequency
+			c.parts = append(c.parts, part)
+		} else {
+			mapping, err := readMapping(part)
+			if err != nil {
+				return nil, err
+			}
+			c.mapping = mapping
+			c.parts = append(c.parts, "mapping")
+		}
+	}
+	if len(c.parts) == 0 {
+		return nil, errors.New("No key classes given")
+	}
+	return c, nil
+}
+
+/*Class names the class of a request for a key with the
+given recomputation cost*/
+func (c *Classifier) Class(key string, cost int) string {
+	names := make([]string, len(c.parts))
+	for i, part := range c.parts {
+		if part == "cost" {
+			names[i] = "cost_" + decade(cost)
+		} else if part == "frequency" {
+			names[i] = "freq_" + decade(c.frequency[key])
+		} else if class, ok := c.mapping[key]; ok {
+			names[i] = class
+		} else {
+			names[i] = Other
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+/*decade writes the order of magnitude of n as 1eN, or 0*/
+func decade(n int) string {
+	if n <= 0 {
+		return "0"
+	}
+	exponent := 0
+	for n >= 10 {
+		n = n / 10
+		exponent++
+	}
+	return "1e" + strconv.Itoa(exponent)
+}
+
+func readMapping(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	mapping := map[string]string{}
+	reader := csv.NewReader(file)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 2 {
+			return nil, errors.New("Mapping rows need a key and a class: " + strings.Join(row, ","))
+		}
+		mapping[row[0]] = row[1]
+	}
+	return mapping, nil
+}
+
+func countKeys(traces []string, format string) (map[string]int, error) {
+	counts := map[string]int{}
+	err := trace.Each(traces, format, func(request trace.Request) error {
+		counts[request.Key]++
+		return nil
+	})
+	return counts, err
+}
@@ -0,0 +1,88 @@
+package classes
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*writeFile puts contents in a file under dir and returns its path*/
+func writeFile(t *testing.T, dir string, name string, contents string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestClass(t *testing.T) {
+	dir := t.TempDir()
+	mapping := writeFile(t, dir, "classes.csv", "key1,frequent\nkey2,costly\n")
+	// key1 12 times, key2 once and key3 100 times, across two traces
+	traces := []string{
+		writeFile(t, dir, "a.csv", strings.Repeat("key1\n", 10)+"key2\n"+strings.Repeat("key3\n", 50)),
+		writeFile(t, dir, "b.csv", strings.Repeat("key1\n", 2)+strings.Repeat("key3\n", 50)),
+	}
+	cases := []struct {
+		spec string
+		key  string
+		cost int
+		want string
+	}{
+		{mapping, "key1", 10, "frequent"},
+		{mapping, "key2", 10, "costly"},
+		{mapping, "key3", 10, Other},
+		{"cost", "key1", 0, "cost_0"},
+		{"cost", "key1", -5, "cost_0"},
+		{"cost", "key1", 1, "cost_1e0"},
+		{"cost", "key1", 9, "cost_1e0"},
+		{"cost", "key1", 10, "cost_1e1"},
+		{"cost", "key1", 99, "cost_1e1"},
+		{"cost", "key1", 100, "cost_1e2"},
+		{"cost", "key1", 123456, "cost_1e5"},
+		{"frequency", "key1", 0, "freq_1e1"},
+		{"frequency", "key2", 0, "freq_1e0"},
+		{"frequency", "key3", 0, "freq_1e2"},
+		{"frequency", "nope", 0, "freq_0"},
+		{"cost,frequency", "key3", 5000, "cost_1e3/freq_1e2"},
+		{"frequency,cost", "key3", 5000, "freq_1e2/cost_1e3"},
+		{mapping + ",cost", "key2", 50, "costly/cost_1e1"},
+		{" cost , " + mapping + ",,frequency ", "key4", 50, "cost_1e1/" + Other + "/freq_0"},
+	}
+	for _, c := range cases {
+		classifier, err := New(c.spec, traces, "keys")
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+			continue
+		}
+		if got := classifier.Class(c.key, c.cost); got != c.want {
+			t.Errorf("%q: %s costing %d got %q, want %q", c.spec, c.key, c.cost, got, c.want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	trace := writeFile(t, dir, "trace.csv", "key1\n")
+	cases := []struct {
+		spec   string
+		traces []string
+		err    string
+	}{
+		{"", []string{trace}, "No key classes given"},
+		{" , ", []string{trace}, "No key classes given"},
+		{"frequency", nil, "Frequency classes need a trace to count keys in"},
+		{"frequency", []string{trace, "-"}, "Frequency classes read the trace twice, which stdin can't do"},
+		{"frequency", []string{filepath.Join(dir, "missing.csv")}, "no such file"},
+		{filepath.Join(dir, "missing.csv"), nil, "no such file"},
+		{writeFile(t, dir, "short.csv", "key1\n"), nil, "Mapping rows need a key and a class: key1"},
+		{writeFile(t, dir, "ragged.csv", "key1,a\nkey2,b,c\n"), nil, "wrong number of fields"},
+	}
+	for _, c := range cases {
+		_, err := New(c.spec, c.traces, "keys")
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: got %v, want an error containing %q", c.spec, err, c.err)
+		}
+	}
+}
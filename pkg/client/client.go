@@ -0,0 +1,375 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/wire"
+)
+
+/*ErrNotFound is returned for keys the server's dataset doesn't have*/
+var ErrNotFound = errors.New("Key not found")
+
+/*Config says where the server is and how hard to try reaching it*/
+type Config struct {
+	// "tcp" or "unix"
+	Network string
+	// host:port, or a socket path for unix
+	Address string
+	// "text" for the fetch protocol or "binary" for pkg/wire
+	Protocol string
+	// how many idle binary connections to keep around
+	PoolSize int
+	// limit on each attempt, on top of any context deadline
+	Timeout time.Duration
+	// extra attempts after a network failure
+	Retries int
+	// pause before the first retry, doubling each time after
+	RetryBackoff time.Duration
+}
+
+/*Result is the answer for one key.  Cost is what the request
+paid (0 on a hit) and Saved is what a hit avoided paying*/
+type Result struct {
+	Key   string
+	Value string
+	Cost  int
+	Saved int
+	Hit   bool
+	Err   error
+}
+
+/*Client fetches keys from a cache server.  It is safe for
+concurrent use; binary connections are pooled, while the text
+protocol needs a new connection for every request*/
+type Client struct {
+	config Config
+	pool   chan *wire.Conn
+}
+
+/*New builds a client, filling in defaults for anything left zero*/
+func New(config Config) *Client {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Address == "" {
+		config.Address = "localhost:1234"
+	}
+	if config.Protocol == "" {
+		config.Protocol = "text"
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 4
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.RetryBackoff == 0 {
+		config.RetryBackoff = 50 * time.Millisecond
+	}
+	return &Client{config: config, pool: make(chan *wire.Conn, config.PoolSize)}
+}
+
+/*Fetch asks for one key, returning its value, the cost the request
+paid and whether it was a cache hit.  A key the server can't find
+comes back as ErrNotFound*/
+func (c *Client) Fetch(ctx context.Context, key string) (string, int, bool, error) {
+	result, err := c.FetchResult(ctx, key)
+	return result.Value, result.Cost, result.Hit, err
+}
+
+/*FetchResult is Fetch with the whole Result, including the cost a
+hit saved.  A key the server can't find comes back as ErrNotFound,
+alongside a Result carrying the same error*/
+func (c *Client) FetchResult(ctx context.Context, key string) (Result, error) {
+	results, err := c.fetch(ctx, []string{key}, false)
+	if err != nil {
+		return Result{Key: key, Err: err}, err
+	}
+	return results[0], results[0].Err
+}
+
+/*FetchBatch asks for several keys in one request.  The server
+consults the cache in key order, and results come back in the
+same order; keys that can't be found carry ErrNotFound*/
+func (c *Client) FetchBatch(ctx context.Context, keys []string) ([]Result, error) {
+	if len(keys) == 0 {
+		return []Result{}, nil
+	}
+	return c.fetch(ctx, keys, true)
+}
+
+/*Stats returns the server's counters as KEY:value lines*/
+func (c *Client) Stats(ctx context.Context) (string, error) {
+	var summary string
+	err := c.retry(ctx, func(ctx context.Context) error {
+		if c.config.Protocol == "binary" {
+			return c.withConn(ctx, func(conn *wire.Conn) error {
+				var err error
+				summary, err = conn.Stats()
+				return err
+			})
+		}
+		lines, err := c.textRequest(ctx, "stats\n")
+		summary = strings.Join(lines, "\n") + "\n"
+		return err
+	})
+	return summary, err
+}
+
+/*Close hangs up any pooled connections*/
+func (c *Client) Close() error {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) fetch(ctx context.Context, keys []string, batch bool) ([]Result, error) {
+	var results []Result
+	err := c.retry(ctx, func(ctx context.Context) error {
+		var err error
+		if c.config.Protocol == "binary" {
+			results, err = c.binaryFetch(ctx, keys, batch)
+		} else if batch {
+			results, err = c.textBatch(ctx, keys)
+		} else {
+			results, err = c.textFetch(ctx, keys[0])
+		}
+		return err
+	})
+	return results, err
+}
+
+/*retry runs an attempt until it works, the retries run out or
+the context is done.  Only network failures are retried*/
+func (c *Client) retry(ctx context.Context, attempt func(context.Context) error) error {
+	backoff := c.config.RetryBackoff
+	var err error
+	for try := 0; try <= c.config.Retries; try++ {
+		if try > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = backoff * 2
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		err = attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if _, isNetErr := err.(net.Error); !isNetErr && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.config.Network, c.config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+/*withConn borrows a pooled binary connection (dialing one if
+the pool is empty) and returns it afterwards unless it failed*/
+func (c *Client) withConn(ctx context.Context, use func(*wire.Conn) error) error {
+	var conn *wire.Conn
+	select {
+	case conn = <-c.pool:
+	default:
+		raw, err := c.dial(ctx)
+		if err != nil {
+			return err
+		}
+		conn = wire.NewConn(raw, 0)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	err := use(conn)
+	if err != nil {
+		if _, isNetErr := err.(net.Error); isNetErr || err == io.EOF || err == io.ErrUnexpectedEOF {
+			conn.Close()
+			return err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+	return err
+}
+
+func (c *Client) binaryFetch(ctx context.Context, keys []string, batch bool) ([]Result, error) {
+	results := make([]Result, len(keys))
+	err := c.withConn(ctx, func(conn *wire.Conn) error {
+		op := wire.OpFetch
+		if batch {
+			op = wire.OpMFetch
+		}
+		resp, err := conn.Do(&wire.Request{Op: op, Keys: keys})
+		if err != nil {
+			return err
+		}
+		if len(resp.Results) != len(keys) {
+			return errors.New("Expected " + strconv.Itoa(len(keys)) + " results, got " + strconv.Itoa(len(resp.Results)))
+		}
+		for i, wireResult := range resp.Results {
+			results[i] = Result{
+				Key:   keys[i],
+				Value: wireResult.Value,
+				Cost:  int(wireResult.Cost),
+				Saved: int(wireResult.Saved),
+				Hit:   wireResult.Hit,
+			}
+			if wireResult.Status == wire.StatusNotFound {
+				results[i].Err = ErrNotFound
+			} else if wireResult.Status != wire.StatusOK {
+				results[i].Err = wire.StatusError(wireResult.Status)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+/*textRequest sends one text command and collects the response
+lines until the server hangs up*/
+func (c *Client) textRequest(ctx context.Context, message string) ([]string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(message))
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	connBuff := bufio.NewReader(conn)
+	for {
+		line, err := connBuff.ReadString('\n')
+		line = strings.TrimRight(line, "\n")
+		if err == io.EOF {
+			if line != "" {
+				lines = append(lines, line)
+			}
+			if len(lines) == 0 {
+				// the server always answers, so this was a dropped
+				// connection rather than an empty reply
+				return nil, io.ErrUnexpectedEOF
+			}
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (c *Client) textFetch(ctx context.Context, key string) ([]Result, error) {
+	lines, err := c.textRequest(ctx, "fetch,"+key)
+	if err != nil {
+		return nil, err
+	}
+	result := Result{Key: key}
+	found := false
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			return nil, errors.New("Unsure how to parse response line: " + line)
+		}
+		if parts[0] == "VALUE" {
+			result.Value = parts[1]
+			found = true
+		} else if parts[0] == "COST" {
+			result.Cost, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, errors.New("Bad cost in response line: " + line)
+			}
+		} else if parts[0] == "SAVED" {
+			result.Saved, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, errors.New("Bad saved cost in response line: " + line)
+			}
+		} else if parts[0] == "HIT" {
+			result.Hit = parts[1] == "true"
+		} else if parts[0] == "No Entry For Key" {
+			result.Err = ErrNotFound
+		} else {
+			return nil, errors.New("Unsure how to parse response line: " + line)
+		}
+	}
+	if !found && result.Err == nil {
+		return nil, errors.New("No value in response for " + key)
+	}
+	return []Result{result}, nil
+}
+
+func (c *Client) textBatch(ctx context.Context, keys []string) ([]Result, error) {
+	lines, err := c.textRequest(ctx, "mfetch,"+strings.Join(keys, ",")+"\n")
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			return nil, errors.New("Unsure how to parse response line: " + line)
+		}
+		if parts[0] == "KEY" {
+			results = append(results, Result{Key: parts[1]})
+			continue
+		}
+		if len(results) == 0 {
+			return nil, errors.New("Response line before any KEY: " + line)
+		}
+		result := &results[len(results)-1]
+		if parts[0] == "VALUE" {
+			result.Value = parts[1]
+		} else if parts[0] == "COST" {
+			result.Cost, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, errors.New("Bad cost in response line: " + line)
+			}
+		} else if parts[0] == "SAVED" {
+			result.Saved, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, errors.New("Bad saved cost in response line: " + line)
+			}
+		} else if parts[0] == "HIT" {
+			result.Hit = parts[1] == "true"
+		} else if parts[0] == "ERROR" {
+			result.Err = ErrNotFound
+		} else {
+			return nil, errors.New("Unsure how to parse response line: " + line)
+		}
+	}
+	if len(results) != len(keys) {
+		return nil, errors.New("Expected " + strconv.Itoa(len(keys)) + " results, got " + strconv.Itoa(len(results)))
+	}
+	return results, nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+/*fakeServer answers every text request with the same reply and
+hangs up, the way the cache server does*/
+func fakeServer(t *testing.T, reply string) *Client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// one read, like the server, since fetch has no newline
+			conn.Read(make([]byte, 1024))
+			conn.Write([]byte(reply))
+			conn.Close()
+		}
+	}()
+	return New(Config{Address: ln.Addr().String()})
+}
+
+func TestFetch(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		value string
+		cost  int
+		hit   bool
+		err   string
+	}{
+		{"miss", "VALUE:val1\nCOST:10\nSAVED:0\nHIT:false\n", "val1", 10, false, ""},
+		{"hit", "VALUE:val1\nCOST:0\nSAVED:10\nHIT:true\n", "val1", 0, true, ""},
+		{"miss on a free key", "VALUE:val1\nCOST:0\nSAVED:0\nHIT:false\n", "val1", 0, false, ""},
+		{"not found", "No Entry For Key: key1\n", "", 0, false, ErrNotFound.Error()},
+		{"no value", "COST:10\n", "", 0, false, "No value in response for key1"},
+		{"bad cost", "VALUE:val1\nCOST:x\n", "", 0, false, "Bad cost in response line: COST:x"},
+		{"garbage", "Bad Command", "", 0, false, "Unsure how to parse response line: Bad Command"},
+	}
+	for _, c := range cases {
+		value, cost, hit, err := fakeServer(t, c.reply).Fetch(context.Background(), "key1")
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil || value != c.value || cost != c.cost || hit != c.hit {
+			t.Errorf("%s: got %q, %d, %v, %v, want %q, %d, %v", c.name, value, cost, hit, err, c.value, c.cost, c.hit)
+		}
+	}
+}
+
+func TestFetchBatch(t *testing.T) {
+	reply := "KEY:key1\nVALUE:val1\nCOST:0\nSAVED:10\nHIT:true\n" +
+		"KEY:nope\nERROR:No Entry For Key: nope\n" +
+		"KEY:key2\nVALUE:val2\nCOST:20\nSAVED:0\nHIT:false\n"
+	results, err := fakeServer(t, reply).FetchBatch(context.Background(), []string{"key1", "nope", "key2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{Key: "key1", Value: "val1", Cost: 0, Saved: 10, Hit: true},
+		{Key: "nope", Err: ErrNotFound},
+		{Key: "key2", Value: "val2", Cost: 20, Hit: false},
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d: got %+v, want %+v", i, results[i], want[i])
+		}
+	}
+	_, err = fakeServer(t, reply).FetchBatch(context.Background(), []string{"key1"})
+	if err == nil {
+		t.Error("expected an error when the server answers for the wrong number of keys")
+	}
+}
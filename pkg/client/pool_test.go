@@ -0,0 +1,269 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/wire"
+)
+
+/*testListener hands each connection it accepts to serve, along
+with its number counting from 0, and counts them*/
+type testListener struct {
+	ln       net.Listener
+	accepted int64
+}
+
+func listen(t *testing.T, serve func(conn net.Conn, n int)) *testListener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl := &testListener{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			n := atomic.AddInt64(&tl.accepted, 1) - 1
+			go serve(conn, int(n))
+		}
+	}()
+	return tl
+}
+
+func (tl *testListener) client(config Config) *Client {
+	config.Address = tl.ln.Addr().String()
+	if config.RetryBackoff == 0 {
+		config.RetryBackoff = time.Millisecond
+	}
+	return New(config)
+}
+
+func (tl *testListener) connections() int {
+	return int(atomic.LoadInt64(&tl.accepted))
+}
+
+/*serveBinary answers binary requests like the server would, over
+a dataset of key1 and key2, until the client hangs up*/
+func serveBinary(conn net.Conn, n int) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	values := map[string]string{"key1": "val1", "key2": "val2"}
+	for {
+		req, err := wire.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		resp := &wire.Response{Status: wire.StatusOK}
+		if req.Op == wire.OpStats {
+			resp.Results = []wire.Result{{Value: "REQUESTS:1\n"}}
+		} else if req.Op == wire.OpDelete {
+			resp.Status = wire.StatusBadRequest
+		}
+		for _, key := range req.Keys {
+			value, ok := values[key]
+			if !ok {
+				resp.Results = append(resp.Results, wire.Result{Status: wire.StatusNotFound})
+				continue
+			}
+			resp.Results = append(resp.Results, wire.Result{Status: wire.StatusOK, Hit: true, Saved: 10, Value: value})
+		}
+		if wire.WriteResponse(writer, resp) != nil {
+			return
+		}
+	}
+}
+
+/*dropFirst hangs up on the first count connections without a
+word, then serves the rest*/
+func dropFirst(count int, serve func(net.Conn, int)) func(net.Conn, int) {
+	return func(conn net.Conn, n int) {
+		if n < count {
+			conn.Close()
+			return
+		}
+		serve(conn, n)
+	}
+}
+
+/*stall reads whatever comes and never answers*/
+func stall(conn net.Conn, n int) {
+	defer conn.Close()
+	buf := make([]byte, 1024)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+func TestBinaryProtocol(t *testing.T) {
+	tl := listen(t, serveBinary)
+	c := tl.client(Config{Protocol: "binary", PoolSize: 1})
+	defer c.Close()
+	ctx := context.Background()
+	value, cost, hit, err := c.Fetch(ctx, "key1")
+	if err != nil || value != "val1" || cost != 0 || !hit {
+		t.Errorf("fetch: got %q, %d, %v, %v", value, cost, hit, err)
+	}
+	_, _, _, err = c.Fetch(ctx, "nope")
+	if err != ErrNotFound {
+		t.Errorf("fetch of a missing key: got %v, want ErrNotFound", err)
+	}
+	results, err := c.FetchBatch(ctx, []string{"key2", "nope"})
+	want := []Result{{Key: "key2", Value: "val2", Saved: 10, Hit: true}, {Key: "nope", Err: ErrNotFound}}
+	if err != nil || len(results) != 2 || results[0] != want[0] || results[1] != want[1] {
+		t.Errorf("batch: got %+v, %v", results, err)
+	}
+	summary, err := c.Stats(ctx)
+	if err != nil || summary != "REQUESTS:1\n" {
+		t.Errorf("stats: got %q, %v", summary, err)
+	}
+	if tl.connections() != 1 {
+		t.Errorf("four requests took %d connections, want one pooled connection", tl.connections())
+	}
+}
+
+func TestPoolUnderConcurrency(t *testing.T) {
+	tl := listen(t, serveBinary)
+	c := tl.client(Config{Protocol: "binary", PoolSize: 2})
+	defer c.Close()
+	var workers sync.WaitGroup
+	var failures int64
+	for i := 0; i < 8; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := 0; j < 50; j++ {
+				value, _, _, err := c.Fetch(context.Background(), "key1")
+				if err != nil || value != "val1" {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}()
+	}
+	workers.Wait()
+	if failures > 0 {
+		t.Errorf("%d of 400 concurrent fetches failed", failures)
+	}
+	if len(c.pool) > 2 {
+		t.Errorf("the pool kept %d connections, more than its size of 2", len(c.pool))
+	}
+	if tl.connections() > 8 {
+		t.Errorf("8 workers dialed %d connections", tl.connections())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	textReply := func(conn net.Conn, n int) {
+		conn.Read(make([]byte, 1024))
+		conn.Write([]byte("VALUE:val1\nCOST:10\nSAVED:0\nHIT:false\n"))
+		conn.Close()
+	}
+	cases := []struct {
+		name     string
+		protocol string
+		serve    func(net.Conn, int)
+		retries  int
+		ok       bool
+		attempts int
+	}{
+		{"binary retried", "binary", dropFirst(2, serveBinary), 2, true, 3},
+		{"binary out of retries", "binary", dropFirst(2, serveBinary), 1, false, 2},
+		{"text retried", "text", dropFirst(1, textReply), 1, true, 2},
+		{"text out of retries", "text", dropFirst(3, textReply), 2, false, 3},
+	}
+	for _, c := range cases {
+		tl := listen(t, c.serve)
+		client := tl.client(Config{Protocol: c.protocol, Retries: c.retries})
+		value, _, _, err := client.Fetch(context.Background(), "key1")
+		if c.ok && (err != nil || value != "val1") {
+			t.Errorf("%s: got %q, %v", c.name, value, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: expected the fetch to fail", c.name)
+		}
+		if tl.connections() != c.attempts {
+			t.Errorf("%s: made %d attempts, want %d", c.name, tl.connections(), c.attempts)
+		}
+		client.Close()
+	}
+}
+
+func TestNoRetryForServerErrors(t *testing.T) {
+	tl := listen(t, serveBinary)
+	c := tl.client(Config{Protocol: "binary", Retries: 3})
+	defer c.Close()
+	// the fake server refuses deletes with a bad request status
+	err := c.withConn(context.Background(), func(conn *wire.Conn) error {
+		return conn.Delete("key1")
+	})
+	if err == nil || err.Error() != "Bad request" {
+		t.Errorf("got %v, want the bad request status", err)
+	}
+	_, _, _, err = c.Fetch(context.Background(), "key1")
+	if err != nil || tl.connections() != 1 {
+		t.Errorf("the connection should go back to the pool after a status error, got %v over %d connections", err, tl.connections())
+	}
+	_, _, _, err = c.Fetch(context.Background(), "nope")
+	if err != ErrNotFound || tl.connections() != 1 {
+		t.Errorf("a missing key isn't worth retrying, got %v over %d connections", err, tl.connections())
+	}
+}
+
+func TestRefusedConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	c := New(Config{Address: address, Retries: 2, RetryBackoff: 10 * time.Millisecond})
+	start := time.Now()
+	_, _, _, err = c.Fetch(context.Background(), "key1")
+	if _, isNetErr := err.(net.Error); !isNetErr {
+		t.Errorf("got %v, want a network error", err)
+	}
+	// backing off 10ms then 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("gave up after %v, before backing off twice", elapsed)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	for _, protocol := range []string{"text", "binary"} {
+		tl := listen(t, stall)
+		c := tl.client(Config{Protocol: protocol, Timeout: 50 * time.Millisecond, Retries: 1})
+		start := time.Now()
+		_, _, _, err := c.Fetch(context.Background(), "key1")
+		elapsed := time.Since(start)
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("%s: got %v, want a timeout", protocol, err)
+		}
+		if elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+			t.Errorf("%s: two 50ms attempts took %v", protocol, elapsed)
+		}
+		if tl.connections() != 2 {
+			t.Errorf("%s: a timeout should be retried, made %d attempts", protocol, tl.connections())
+		}
+		c.Close()
+	}
+	// a context deadline shorter than the timeout wins
+	tl := listen(t, stall)
+	c := tl.client(Config{Protocol: "binary", Timeout: 10 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err := c.Fetch(ctx, "key1")
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("got %v after %v, want a failure at the context deadline", err, time.Since(start))
+	}
+}
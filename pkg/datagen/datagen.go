@@ -0,0 +1,92 @@
+package datagen
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+/*MaxCost caps every cost, since a heavy tailed draw can be too big
+for an int and a client totals costs over the whole trace.  Even
+a billion requests all at MaxCost stay clear of overflowing*/
+const MaxCost = math.MaxInt32
+
+/*Costs draws one cost per key, rounded and floored at 1 so a
+cost of 0 never looks like a cache hit to the text client*/
+func Costs(dist Distribution, count int) []int {
+	costs := make([]int, count)
+	for i := range costs {
+		costs[i] = roundCost(dist.Sample())
+	}
+	return costs
+}
+
+/*roundCost keeps a cost whole and between 1 and MaxCost*/
+func roundCost(cost float64) int {
+	if cost >= MaxCost {
+		return MaxCost
+	}
+	// written this way round so NaN is floored too
+	if !(cost >= 1) {
+		return 1
+	}
+	return int(math.Round(cost))
+}
+
+/*Correlate reorders costs so they follow popularity (key index 0
+being the most popular) with roughly the given correlation: 1
+makes the most popular keys the most expensive, -1 the cheapest
+and 0 leaves the costs in random order.  Each key gets a score
+mixing its popularity with noise, and the sorted costs are handed
+out in score order*/
+func Correlate(costs []int, popularity []int, correlation float64, rng *rand.Rand) []int {
+	count := len(costs)
+	noise := math.Sqrt(math.Max(0, 1-correlation*correlation))
+	scores := make([]float64, count)
+	for rank, key := range popularity {
+		// the popularity rank scaled to unit variance, popular keys high
+		standard := math.Sqrt(12) * (0.5 - (float64(rank)+0.5)/float64(count))
+		scores[key] = correlation*standard + noise*rng.NormFloat64()
+	}
+	byScore := make([]int, count)
+	for i := range byScore {
+		byScore[i] = i
+	}
+	sort.SliceStable(byScore, func(i, j int) bool { return scores[byScore[i]] < scores[byScore[j]] })
+	sorted := append([]int{}, costs...)
+	sort.Ints(sorted)
+	correlated := make([]int, count)
+	for i, key := range byScore {
+		correlated[key] = sorted[i]
+	}
+	return correlated
+}
+
+/*PopularityFromCounts ranks keys 0..count-1 by how often a trace
+requested them, most requested first, with ties in key order*/
+func PopularityFromCounts(counts map[int]int, count int) []int {
+	ranked := make([]int, count)
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return counts[ranked[i]] > counts[ranked[j]] })
+	return ranked
+}
+
+const valueLetters = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+/*Value makes a value of the given size out of letters and
+digits, which every protocol the server speaks can carry*/
+func Value(size int, rng *rand.Rand) string {
+	value := make([]byte, size)
+	for i := range value {
+		value[i] = valueLetters[rng.Intn(len(valueLetters))]
+	}
+	return string(value)
+}
+
+/*DefaultValue is the value test_set_1.csv uses for key n*/
+func DefaultValue(n int) string {
+	return "val" + strconv.Itoa(n)
+}
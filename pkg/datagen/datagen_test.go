@@ -0,0 +1,74 @@
+package datagen
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRoundCost(t *testing.T) {
+	cases := []struct {
+		in   float64
+		want int
+	}{
+		{1000.4, 1000},
+		{1000.5, 1001},
+		{0.2, 1},
+		{-5, 1},
+		{math.NaN(), 1},
+		{math.Inf(-1), 1},
+		{math.Inf(1), MaxCost},
+		{1e30, MaxCost},
+		{float64(math.MaxInt64), MaxCost},
+		{MaxCost - 0.2, MaxCost},
+	}
+	for _, c := range cases {
+		if got := roundCost(c.in); got != c.want {
+			t.Errorf("roundCost(%v) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestCostsStayInRange(t *testing.T) {
+	specs := []string{"pareto:xm=1e15,alpha=0.05", "lognormal:mu=60,sigma=5", "normal:mean=0,std=10"}
+	for _, spec := range specs {
+		dist, err := ParseDistribution(spec, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cost := range Costs(dist, 10000) {
+			if cost < 1 || cost > MaxCost {
+				t.Fatalf("%s: cost %d out of range", spec, cost)
+			}
+		}
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	cases := []struct {
+		spec string
+		err  string
+	}{
+		{"fixed", ""},
+		{"fixed:value=7", ""},
+		{"pareto: xm = 10 , alpha = 2", ""},
+		{"zipf", "Unknown distribution: zipf"},
+		{"fixed:amount=7", "Bad parameter for fixed: amount=7"},
+		{"fixed:value", "Bad parameter for fixed: value"},
+		{"fixed:value=x", "Bad value for fixed value: x"},
+		{"uniform:min=10,max=1", "uniform needs min <= max"},
+		{"pareto:alpha=0", "pareto needs positive xm and alpha"},
+	}
+	for _, c := range cases {
+		_, err := ParseDistribution(c.spec, rand.New(rand.NewSource(1)))
+		if c.err == "" && err != nil {
+			t.Errorf("%s: %v", c.spec, err)
+		} else if c.err != "" && (err == nil || err.Error() != c.err) {
+			t.Errorf("%s: got error %v, want %q", c.spec, err, c.err)
+		}
+	}
+	dist, _ := ParseDistribution("fixed:value=7", nil)
+	if got := Costs(dist, 3); got[0] != 7 || got[1] != 7 || got[2] != 7 {
+		t.Errorf("fixed:value=7 drew %v", got)
+	}
+}
@@ -0,0 +1,103 @@
+package datagen
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*Distribution draws numbers, for costs or value sizes*/
+type Distribution interface {
+	Sample() float64
+}
+
+/*Distributions lists the names ParseDistribution accepts, each
+with the parameters it takes and their defaults*/
+var Distributions = map[string]map[string]float64{
+	"fixed":     {"value": 1000},
+	"uniform":   {"min": 1000, "max": 100000},
+	"normal":    {"mean": 10000, "std": 3000},
+	"lognormal": {"mu": 9, "sigma": 1},
+	"pareto":    {"xm": 1000, "alpha": 1.16},
+	"bimodal":   {"low_mean": 1000, "low_std": 200, "high_mean": 100000, "high_std": 20000, "high_prob": 0.1},
+}
+
+/*DistributionNames lists the distributions in order, for help text*/
+func DistributionNames() []string {
+	names := []string{}
+	for name := range Distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*ParseDistribution reads a spec like "pareto:xm=1000,alpha=1.2",
+filling in defaults for any parameter left out*/
+func ParseDistribution(spec string, rng *rand.Rand) (Distribution, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	defaults, ok := Distributions[name]
+	if !ok {
+		return nil, errors.New("Unknown distribution: " + name)
+	}
+	params := map[string]float64{}
+	for param, value := range defaults {
+		params[param] = value
+	}
+	if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
+		for _, pair := range strings.Split(parts[1], ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			param := strings.TrimSpace(kv[0])
+			if _, known := defaults[param]; !known || len(kv) != 2 {
+				return nil, errors.New("Bad parameter for " + name + ": " + pair)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil {
+				return nil, errors.New("Bad value for " + name + " " + param + ": " + kv[1])
+			}
+			params[param] = value
+		}
+	}
+	return build(name, params, rng)
+}
+
+func build(name string, p map[string]float64, rng *rand.Rand) (Distribution, error) {
+	if name == "fixed" {
+		return sampler(func() float64 { return p["value"] }), nil
+	} else if name == "uniform" {
+		if p["max"] < p["min"] {
+			return nil, errors.New("uniform needs min <= max")
+		}
+		return sampler(func() float64 { return p["min"] + rng.Float64()*(p["max"]-p["min"]) }), nil
+	} else if name == "normal" {
+		return sampler(func() float64 { return p["mean"] + rng.NormFloat64()*p["std"] }), nil
+	} else if name == "lognormal" {
+		return sampler(func() float64 { return math.Exp(p["mu"] + rng.NormFloat64()*p["sigma"]) }), nil
+	} else if name == "pareto" {
+		if p["xm"] <= 0 || p["alpha"] <= 0 {
+			return nil, errors.New("pareto needs positive xm and alpha")
+		}
+		return sampler(func() float64 {
+			return p["xm"] / math.Pow(1-rng.Float64(), 1/p["alpha"])
+		}), nil
+	} else if name == "bimodal" {
+		return sampler(func() float64 {
+			if rng.Float64() < p["high_prob"] {
+				return p["high_mean"] + rng.NormFloat64()*p["high_std"]
+			}
+			return p["low_mean"] + rng.NormFloat64()*p["low_std"]
+		}), nil
+	}
+	return nil, errors.New("Unknown distribution: " + name)
+}
+
+/*sampler lets a closure serve as a Distribution*/
+type sampler func() float64
+
+func (s sampler) Sample() float64 {
+	return s()
+}
@@ -0,0 +1,246 @@
+package datagen
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+	"github.com/evizitei/lcr-cache/pkg/trace"
+)
+
+/*Transform rewrites the costs of a dataset in place, leaving keys,
+values and their order alone*/
+type Transform func(records []dataset.Record)
+
+/*Transforms lists the names ParseTransform accepts, each with the
+parameters it takes and their defaults.  An empty default means
+the parameter has to be given*/
+var Transforms = map[string]map[string]string{
+	"rescale":    {"direction": "up", "factor": "1", "base": "1000"},
+	"shuffle":    {},
+	"noise":      {"sigma": "0.1"},
+	"clamp":      {"min": "1", "max": "0"},
+	"invert":     {},
+	"popularity": {"trace": "", "format": "keys", "fn": "rank", "order": "desc", "scale": "1000", "exponent": "1"},
+}
+
+/*TransformNames lists the transforms in order, for help text*/
+func TransformNames() []string {
+	names := []string{}
+	for name := range Transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*ParseTransform reads a spec like "rescale:direction=down,factor=50",
+filling in defaults for any parameter left out.  The transforms:
+
+  rescale:    move each cost's distance from base up (times factor)
+              or down (divided by factor), like cost_adjuster.py
+  shuffle:    hand the same costs out to the keys at random
+  noise:      multiply each cost by lognormal noise with the given sigma
+  clamp:      floor costs at min and cap them at max (0 for no cap)
+  invert:     reverse the cost ranking, so the cheapest key gets the
+              highest cost and the other way around
+  popularity: remap costs by how often the trace requests each key.
+              fn=rank hands the same costs out by popularity, the most
+              popular key getting the highest (order=desc) or lowest
+              (order=asc) cost; fn=power sets each cost to
+              scale * ((requests+1) / (mean requests+1))^exponent
+
+Costs are rounded and kept between 1 and MaxCost after every
+transform*/
+func ParseTransform(spec string, rng *rand.Rand) (Transform, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	defaults, ok := Transforms[name]
+	if !ok {
+		return nil, errors.New("Unknown transform: " + name)
+	}
+	params := map[string]string{}
+	for param, value := range defaults {
+		params[param] = value
+	}
+	if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
+		for _, pair := range strings.Split(parts[1], ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			param := strings.TrimSpace(kv[0])
+			if _, known := defaults[param]; !known || len(kv) != 2 {
+				return nil, errors.New("Bad parameter for " + name + ": " + pair)
+			}
+			params[param] = strings.TrimSpace(kv[1])
+		}
+	}
+	for param, value := range params {
+		if value == "" {
+			return nil, errors.New(name + " needs " + param)
+		}
+	}
+	return buildTransform(name, transformParams{name: name, values: params}, rng)
+}
+
+/*transformParams reads a transform's parameters, remembering the
+first one that doesn't parse*/
+type transformParams struct {
+	name   string
+	values map[string]string
+	err    error
+}
+
+func (p *transformParams) number(param string) float64 {
+	value, err := strconv.ParseFloat(p.values[param], 64)
+	if err != nil && p.err == nil {
+		p.err = errors.New("Bad value for " + p.name + " " + param + ": " + p.values[param])
+	}
+	return value
+}
+
+func buildTransform(name string, p transformParams, rng *rand.Rand) (Transform, error) {
+	if name == "rescale" {
+		return rescale(&p)
+	} else if name == "shuffle" {
+		return func(records []dataset.Record) {
+			costs := costsOf(records)
+			rng.Shuffle(len(costs), func(i, j int) { costs[i], costs[j] = costs[j], costs[i] })
+			setCosts(records, costs)
+		}, nil
+	} else if name == "noise" {
+		sigma := p.number("sigma")
+		return func(records []dataset.Record) {
+			for i := range records {
+				records[i].Cost = roundCost(float64(records[i].Cost) * math.Exp(rng.NormFloat64()*sigma))
+			}
+		}, p.err
+	} else if name == "clamp" {
+		low, high := int(p.number("min")), int(p.number("max"))
+		if p.err == nil && high > 0 && high < low {
+			return nil, errors.New("clamp needs min <= max")
+		}
+		return func(records []dataset.Record) {
+			for i := range records {
+				if records[i].Cost < low {
+					records[i].Cost = low
+				} else if high > 0 && records[i].Cost > high {
+					records[i].Cost = high
+				}
+				records[i].Cost = roundCost(float64(records[i].Cost))
+			}
+		}, p.err
+	} else if name == "invert" {
+		return func(records []dataset.Record) {
+			byCost := rankBy(len(records), func(i, j int) bool { return records[i].Cost < records[j].Cost })
+			costs := costsOf(records)
+			sort.Ints(costs)
+			for i, record := range byCost {
+				records[record].Cost = costs[len(costs)-1-i]
+			}
+		}, nil
+	} else if name == "popularity" {
+		return popularity(&p)
+	}
+	return nil, errors.New("Unknown transform: " + name)
+}
+
+/*rescale is cost_adjuster.py: the distance of each cost from base
+grows factor times going up, or shrinks factor times going down*/
+func rescale(p *transformParams) (Transform, error) {
+	factor, base := p.number("factor"), p.number("base")
+	direction := p.values["direction"]
+	if p.err != nil {
+		return nil, p.err
+	}
+	if direction != "up" && direction != "down" {
+		return nil, errors.New("No such direction: " + direction)
+	}
+	if direction == "down" && factor == 0 {
+		return nil, errors.New("rescale can't divide by a factor of 0")
+	}
+	return func(records []dataset.Record) {
+		for i := range records {
+			delta := float64(records[i].Cost) - base
+			if direction == "up" {
+				delta = delta * factor
+			} else {
+				delta = delta / factor
+			}
+			// truncated like the python script's int()
+			records[i].Cost = roundCost(base + math.Trunc(delta))
+		}
+	}, nil
+}
+
+func popularity(p *transformParams) (Transform, error) {
+	fn, order := p.values["fn"], p.values["order"]
+	scale, exponent := p.number("scale"), p.number("exponent")
+	if p.err != nil {
+		return nil, p.err
+	}
+	if fn != "rank" && fn != "power" {
+		return nil, errors.New("popularity fn must be rank or power, not " + fn)
+	}
+	if order != "desc" && order != "asc" {
+		return nil, errors.New("popularity order must be desc or asc, not " + order)
+	}
+	counts := map[string]int{}
+	err := trace.Each(strings.Split(p.values["trace"], ";"), p.values["format"], func(request trace.Request) error {
+		counts[request.Key]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(records []dataset.Record) {
+		if fn == "rank" {
+			byPopularity := rankBy(len(records), func(i, j int) bool { return counts[records[i].Key] > counts[records[j].Key] })
+			costs := costsOf(records)
+			sort.Ints(costs)
+			for i, record := range byPopularity {
+				if order == "desc" {
+					records[record].Cost = costs[len(costs)-1-i]
+				} else {
+					records[record].Cost = costs[i]
+				}
+			}
+			return
+		}
+		total := 0
+		for _, record := range records {
+			total += counts[record.Key]
+		}
+		mean := float64(total) / float64(len(records))
+		for i := range records {
+			relative := (float64(counts[records[i].Key]) + 1) / (mean + 1)
+			records[i].Cost = roundCost(scale * math.Pow(relative, exponent))
+		}
+	}, nil
+}
+
+/*rankBy orders record indexes by less, ties staying in file order*/
+func rankBy(count int, less func(i, j int) bool) []int {
+	ranked := make([]int, count)
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return less(ranked[a], ranked[b]) })
+	return ranked
+}
+
+func costsOf(records []dataset.Record) []int {
+	costs := make([]int, len(records))
+	for i, record := range records {
+		costs[i] = record.Cost
+	}
+	return costs
+}
+
+func setCosts(records []dataset.Record, costs []int) {
+	for i := range records {
+		records[i].Cost = costs[i]
+	}
+}
@@ -0,0 +1,131 @@
+package datagen
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+func testRecords(costs ...int) []dataset.Record {
+	records := []dataset.Record{}
+	for i, cost := range costs {
+		key := string(rune('a' + i))
+		records = append(records, dataset.Record{Key: key, Value: "val" + key, Cost: cost})
+	}
+	return records
+}
+
+func applyTransform(t *testing.T, spec string, costs ...int) []int {
+	transform, err := ParseTransform(spec, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("%s: %v", spec, err)
+	}
+	records := testRecords(costs...)
+	transform(records)
+	for i, record := range records {
+		if record.Key != testRecords(costs...)[i].Key || record.Value != "val"+record.Key {
+			t.Errorf("%s moved record %d to %+v", spec, i, record)
+		}
+	}
+	return costsOf(records)
+}
+
+func TestTransforms(t *testing.T) {
+	cases := []struct {
+		spec string
+		want []int
+	}{
+		{"rescale", []int{500, 1000, 1500, 3000}},
+		{"rescale:factor=2", []int{1, 1000, 2000, 5000}},
+		{"rescale:direction=down,factor=4", []int{875, 1000, 1125, 1500}},
+		{"rescale:direction=down,factor=3", []int{834, 1000, 1166, 1666}},
+		{"rescale:direction=down,factor=2,base=0", []int{250, 500, 750, 1500}},
+		{"clamp:min=600,max=2000", []int{600, 1000, 1500, 2000}},
+		{"clamp:min=600", []int{600, 1000, 1500, 3000}},
+		{"invert", []int{3000, 1500, 1000, 500}},
+	}
+	for _, c := range cases {
+		if got := applyTransform(t, c.spec, 500, 1000, 1500, 3000); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestShuffleKeepsCosts(t *testing.T) {
+	costs := []int{5, 1, 4, 1, 3, 9, 2, 6}
+	got := applyTransform(t, "shuffle", costs...)
+	sort.Ints(got)
+	want := append([]int{}, costs...)
+	sort.Ints(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shuffle handed out %v from %v", got, costs)
+	}
+}
+
+func TestNoiseStaysInRange(t *testing.T) {
+	for _, cost := range applyTransform(t, "noise:sigma=50", 1, 1000, MaxCost) {
+		if cost < 1 || cost > MaxCost {
+			t.Errorf("noise gave a cost of %d", cost)
+		}
+	}
+}
+
+func TestPopularity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	traceFile := filepath.Join(dir, "trace.csv")
+	// d three times, c twice, b once, a never
+	err = ioutil.WriteFile(traceFile, []byte("d\nd\nd\nc\nc\nb\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		spec string
+		want []int
+	}{
+		{"popularity:fn=rank", []int{500, 1000, 1500, 3000}},
+		{"popularity:fn=rank,order=asc", []int{3000, 1500, 1000, 500}},
+		{"popularity:fn=power", []int{400, 800, 1200, 1600}},
+		{"popularity:fn=power,scale=10,exponent=0", []int{10, 10, 10, 10}},
+	}
+	for _, c := range cases {
+		spec := c.spec + ",trace=" + traceFile
+		if got := applyTransform(t, spec, 3000, 1500, 1000, 500); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestParseTransformErrors(t *testing.T) {
+	cases := []struct {
+		spec string
+		err  string
+	}{
+		{"sharpen", "Unknown transform: sharpen"},
+		{"rescale:speed=2", "Bad parameter for rescale: speed=2"},
+		{"rescale:factor", "Bad parameter for rescale: factor"},
+		{"rescale:factor=lots", "Bad value for rescale factor: lots"},
+		{"rescale:direction=sideways", "No such direction: sideways"},
+		{"rescale:direction=down,factor=0", "rescale can't divide by a factor of 0"},
+		{"noise:sigma=", "noise needs sigma"},
+		{"clamp:min=10,max=5", "clamp needs min <= max"},
+		{"popularity", "popularity needs trace"},
+		{"popularity:trace=x,fn=log", "popularity fn must be rank or power, not log"},
+		{"popularity:trace=x,order=up", "popularity order must be desc or asc, not up"},
+	}
+	for _, c := range cases {
+		_, err := ParseTransform(c.spec, rand.New(rand.NewSource(1)))
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.spec, err, c.err)
+		}
+	}
+}
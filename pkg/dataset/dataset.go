@@ -0,0 +1,73 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*Record is one row of a dataset file: a key, the value
+stored under it and the cost to recompute it*/
+type Record struct {
+	Key   string
+	Value string
+	Cost  int
+}
+
+/*Read loads a key,value,cost CSV into a map by key*/
+func Read(filename string) (map[string]Record, error) {
+	rows, err := ReadOrdered(filename)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]Record, len(rows))
+	for _, record := range rows {
+		records[record.Key] = record
+	}
+	return records, nil
+}
+
+/*ReadOrdered loads a key,value,cost CSV keeping the rows in file order*/
+func ReadOrdered(filename string) ([]Record, error) {
+	file, err := os.OpenFile(filename, os.O_RDONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := []Record{}
+	reader := csv.NewReader(file)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 3 {
+			return nil, errors.New("Dataset rows need a key, value and cost: " + strings.Join(row, ","))
+		}
+		cost, err := strconv.Atoi(row[2])
+		if err != nil {
+			return nil, errors.New("Bad cost for key " + row[0] + ": " + row[2])
+		}
+		records = append(records, Record{Key: row[0], Value: row[1], Cost: cost})
+	}
+	return records, nil
+}
+
+/*Write puts records out as key,value,cost CSV, the format Read takes*/
+func Write(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+	for _, record := range records {
+		err := writer.Write([]string{record.Key, record.Value, strconv.Itoa(record.Cost)})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
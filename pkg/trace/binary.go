@@ -0,0 +1,183 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+/*binaryMagic starts every binary trace, with the version last*/
+var binaryMagic = []byte{'L', 'C', 'R', 'T', 1}
+
+/*binaryKeyMax is the longest key a binary trace may hold, well past
+any real key but short enough that a corrupt length can't make the
+reader allocate much*/
+const binaryKeyMax = 64 * 1024
+
+/*opCodes are the operations as the binary encoding numbers them*/
+var opCodes = map[string]uint64{Get: 0, Set: 1, Delete: 2}
+
+/*A binary trace is the magic, then for every request:
+
+  uvarint  key id << 2 | op (0 get, 1 set, 2 delete)
+  uvarint  key length (at most binaryKeyMax), and the key, the first
+           time an id appears
+  varint   microseconds since the previous request
+  uvarint  size in bytes
+
+Ids count up from 0 in the order keys first appear, so a request
+for a key the trace has seen before is usually 4 or 5 bytes*/
+type binaryReader struct {
+	reader *bufio.Reader
+	keys   []string
+	last   time.Duration
+	count  int
+}
+
+func newBinaryReader(r io.Reader) (*binaryReader, error) {
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(binaryMagic))
+	_, err := io.ReadFull(reader, magic)
+	if err != nil || string(magic[:4]) != string(binaryMagic[:4]) {
+		return nil, errors.New("Not a binary trace")
+	}
+	if magic[4] != binaryMagic[4] {
+		return nil, errors.New("Unsupported binary trace version " + strconv.Itoa(int(magic[4])))
+	}
+	return &binaryReader{reader: reader}, nil
+}
+
+func (b *binaryReader) Read() (Request, error) {
+	head, err := binary.ReadUvarint(b.reader)
+	if err != nil {
+		// running out between requests is the end of the trace
+		return Request{}, err
+	}
+	b.count++
+	id, code := head>>2, head&3
+	if id == uint64(len(b.keys)) {
+		length, err := binary.ReadUvarint(b.reader)
+		if err != nil {
+			return Request{}, b.truncated(err)
+		}
+		if length > binaryKeyMax {
+			return Request{}, errors.New("request " + strconv.Itoa(b.count) + ": key length " + strconv.FormatUint(length, 10) + " over the limit of " + strconv.Itoa(binaryKeyMax))
+		}
+		key := make([]byte, length)
+		_, err = io.ReadFull(b.reader, key)
+		if err != nil {
+			return Request{}, b.truncated(err)
+		}
+		b.keys = append(b.keys, string(key))
+	} else if id > uint64(len(b.keys)) {
+		return Request{}, errors.New("request " + strconv.Itoa(b.count) + ": key id " + strconv.FormatUint(id, 10) + " out of order")
+	}
+	delta, err := binary.ReadVarint(b.reader)
+	if err != nil {
+		return Request{}, b.truncated(err)
+	}
+	size, err := binary.ReadUvarint(b.reader)
+	if err != nil {
+		return Request{}, b.truncated(err)
+	}
+	b.last += time.Duration(delta) * time.Microsecond
+	op := Get
+	if code == opCodes[Set] {
+		op = Set
+	} else if code == opCodes[Delete] {
+		op = Delete
+	}
+	return Request{Timestamp: b.last, Key: b.keys[id], Size: int(size), Op: op}, nil
+}
+
+func (b *binaryReader) truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.New("request " + strconv.Itoa(b.count) + ": " + err.Error())
+}
+
+/*Writer puts requests out in one of the formats that can be
+written: keys or binary*/
+type Writer interface {
+	Write(Request) error
+	Flush() error
+}
+
+/*WriteFormats lists the formats NewWriter can write*/
+var WriteFormats = []string{"keys", "binary"}
+
+/*NewWriter writes requests in the named format.  Timestamps only
+matter to keys, which writes them as a second column of seconds
+when asked; binary always keeps them*/
+func NewWriter(w io.Writer, format string, timestamps bool) (Writer, error) {
+	out := bufio.NewWriterSize(w, 64*1024)
+	if format == "" || format == "keys" {
+		return &keysWriter{out: out, timestamps: timestamps}, nil
+	} else if format == "binary" {
+		_, err := out.Write(binaryMagic)
+		if err != nil {
+			return nil, err
+		}
+		return &binaryWriter{out: out, ids: map[string]uint64{}}, nil
+	}
+	return nil, errors.New("Can't write trace format: " + format + " (one of keys, binary)")
+}
+
+type keysWriter struct {
+	out        *bufio.Writer
+	timestamps bool
+	line       []byte
+}
+
+func (k *keysWriter) Write(request Request) error {
+	k.line = append(k.line[:0], request.Key...)
+	if k.timestamps {
+		k.line = append(k.line, ',')
+		k.line = strconv.AppendFloat(k.line, request.Timestamp.Seconds(), 'f', -1, 64)
+	}
+	k.line = append(k.line, '\n')
+	_, err := k.out.Write(k.line)
+	return err
+}
+
+func (k *keysWriter) Flush() error {
+	return k.out.Flush()
+}
+
+type binaryWriter struct {
+	out    *bufio.Writer
+	ids    map[string]uint64
+	last   time.Duration
+	record []byte
+}
+
+func (b *binaryWriter) Write(request Request) error {
+	id, seen := b.ids[request.Key]
+	if !seen && len(request.Key) > binaryKeyMax {
+		return errors.New("Key too long for a binary trace, " + strconv.Itoa(len(request.Key)) + " bytes")
+	}
+	if !seen {
+		id = uint64(len(b.ids))
+		b.ids[request.Key] = id
+	}
+	b.record = binary.AppendUvarint(b.record[:0], id<<2|opCodes[request.Op])
+	if !seen {
+		b.record = binary.AppendUvarint(b.record, uint64(len(request.Key)))
+		b.record = append(b.record, request.Key...)
+	}
+	// the previous timestamp is rounded the same way, so error doesn't add up
+	micros := request.Timestamp.Microseconds()
+	b.record = binary.AppendVarint(b.record, micros-b.last.Microseconds())
+	b.last = time.Duration(micros) * time.Microsecond
+	b.record = binary.AppendUvarint(b.record, uint64(request.Size))
+	_, err := b.out.Write(b.record)
+	return err
+}
+
+func (b *binaryWriter) Flush() error {
+	return b.out.Flush()
+}
@@ -0,0 +1,143 @@
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var binaryRequests = []Request{
+	{Key: "key1", Op: Get},
+	{Timestamp: 1500 * time.Microsecond, Key: "key2", Size: 4096, Op: Set},
+	{Timestamp: 1500 * time.Microsecond, Key: "key1", Size: 10, Op: Get},
+	{Timestamp: time.Second, Key: "", Op: Delete},
+	{Timestamp: 2 * time.Second, Key: strings.Repeat("k", binaryKeyMax), Op: Get},
+	{Timestamp: 2 * time.Second, Key: "key2", Op: Delete},
+}
+
+func encode(t *testing.T, requests []Request) []byte {
+	var out bytes.Buffer
+	writer, err := NewWriter(&out, "binary", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range requests {
+		err = writer.Write(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	reader, err := NewReader(bytes.NewReader(encode(t, binaryRequests)), "binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests, err := readAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != len(binaryRequests) {
+		t.Fatalf("got %d requests, want %d", len(requests), len(binaryRequests))
+	}
+	for i := range requests {
+		if requests[i] != binaryRequests[i] {
+			t.Errorf("request %d is %+v, want %+v", i, requests[i], binaryRequests[i])
+		}
+	}
+}
+
+/*TestBinaryTruncated cuts an encoding short at every byte: the
+reader has to either stop cleanly between requests or say which
+request was cut off*/
+func TestBinaryTruncated(t *testing.T) {
+	encoded := encode(t, binaryRequests[:4])
+	for cut := len(binaryMagic); cut < len(encoded); cut++ {
+		reader, err := NewReader(bytes.NewReader(encoded[:cut]), "binary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests, err := readAll(reader)
+		if err != nil && !strings.HasPrefix(err.Error(), "request "+strconv.Itoa(len(requests)+1)+": ") {
+			t.Errorf("cut at %d: unexpected error %v", cut, err)
+		}
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	request := func(fields ...uint64) []byte {
+		encoded := append([]byte{}, binaryMagic...)
+		for _, field := range fields {
+			encoded = binary.AppendUvarint(encoded, field)
+		}
+		return encoded
+	}
+	cases := []struct {
+		name    string
+		encoded []byte
+		err     string
+	}{
+		{"huge key length", request(0, 1<<62), "request 1: key length 4611686018427387904 over the limit of 65536"},
+		{"key length just over", request(0, binaryKeyMax+1), "request 1: key length 65537 over the limit of 65536"},
+		{"short key", request(0, 10, 'a'), "request 1: unexpected EOF"},
+		{"id out of order", request(5 << 2), "request 1: key id 5 out of order"},
+		{"second request cut off", append(request(0, 1, 'a', 0, 0), 0), "request 2: unexpected EOF"},
+	}
+	for _, c := range cases {
+		reader, err := NewReader(bytes.NewReader(c.encoded), "binary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = readAll(reader)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+		}
+	}
+	_, err := NewReader(strings.NewReader("key1\n"), "binary")
+	if err == nil || err.Error() != "Not a binary trace" {
+		t.Errorf("plain text: got error %v", err)
+	}
+	_, err = NewReader(bytes.NewReader([]byte{'L', 'C', 'R', 'T', 9}), "binary")
+	if err == nil || err.Error() != "Unsupported binary trace version 9" {
+		t.Errorf("version 9: got error %v", err)
+	}
+	writer, _ := NewWriter(&bytes.Buffer{}, "binary", false)
+	err = writer.Write(Request{Key: strings.Repeat("k", binaryKeyMax+1)})
+	if err == nil {
+		t.Error("writing a key over the limit should fail")
+	}
+}
+
+/*TestOpenSniffs checks that Open finds a gzipped binary trace from
+its contents alone, whatever format it's asked for*/
+func TestOpenSniffs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "trace")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write(encode(t, binaryRequests))
+	gz.Close()
+	file.Close()
+	requests := 0
+	err = Each([]string{filename}, "keys", func(Request) error {
+		requests++
+		return nil
+	})
+	if err != nil || requests != len(binaryRequests) {
+		t.Errorf("got %d requests and %v, want %d", requests, err, len(binaryRequests))
+	}
+}
@@ -0,0 +1,94 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+/*decompress sniffs the first bytes of a trace and unwraps gzip or
+zstd, so a compressed trace needs no flag, even on stdin.  The
+closer releases the decompressor, not the file underneath*/
+func decompress(r io.Reader) (*bufio.Reader, func() error, error) {
+	buffered := bufio.NewReaderSize(r, 64*1024)
+	magic, _ := buffered.Peek(4)
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bufio.NewReaderSize(gz, 64*1024), gz.Close, nil
+	} else if bytes.HasPrefix(magic, zstdMagic) {
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bufio.NewReaderSize(zr, 64*1024), func() error { zr.Close(); return nil }, nil
+	}
+	return buffered, func() error { return nil }, nil
+}
+
+/*Create opens a trace file for writing, compressing it when the
+name ends in .gz or .zst.  "-" (or no name) writes to stdout*/
+func Create(filename string) (io.WriteCloser, error) {
+	var file *os.File
+	if filename == "" || filename == "-" {
+		file = os.Stdout
+	} else {
+		var err error
+		file, err = os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+		if err != nil {
+			return nil, err
+		}
+	}
+	out := &compressedFile{file: file}
+	if strings.HasSuffix(filename, ".gz") {
+		out.compressor = gzip.NewWriter(file)
+	} else if strings.HasSuffix(filename, ".zst") {
+		zw, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		out.compressor = zw
+	}
+	return out, nil
+}
+
+/*compressedFile writes through its compressor, if it has one, and
+finishes the compressed stream before closing the file*/
+type compressedFile struct {
+	file       *os.File
+	compressor io.WriteCloser
+}
+
+func (c *compressedFile) Write(p []byte) (int, error) {
+	if c.compressor != nil {
+		return c.compressor.Write(p)
+	}
+	return c.file.Write(p)
+}
+
+func (c *compressedFile) Close() error {
+	var err error
+	if c.compressor != nil {
+		err = c.compressor.Close()
+	}
+	if c.file == os.Stdout {
+		return err
+	}
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,278 @@
+package trace
+
+import (
+	"encoding/csv"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*sectorSize is the 512 byte sector block traces count in*/
+const sectorSize = 512
+
+/*keysReader reads our own CSV: a key, and optionally the time of
+the request in seconds*/
+type keysReader struct {
+	reader *csv.Reader
+	clock  clock
+}
+
+func (k *keysReader) Read() (Request, error) {
+	row, err := k.reader.Read()
+	if err != nil {
+		return Request{}, err
+	}
+	request := Request{Key: row[0], Op: Get}
+	if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			line, _ := k.reader.FieldPos(0)
+			return Request{}, errors.New("line " + strconv.Itoa(line) + ": bad timestamp " + row[1])
+		}
+		request.Timestamp = k.clock.since(int64(math.Round(seconds*1e6)), time.Microsecond)
+	}
+	return request, nil
+}
+
+/*arcReader expands each run of blocks into a request per block,
+the way the ARC paper replays its traces*/
+type arcReader struct {
+	lines *lines
+	next  int64
+	left  int64
+}
+
+func (a *arcReader) Read() (Request, error) {
+	for a.left == 0 {
+		line, err := a.lines.next()
+		if err != nil {
+			return Request{}, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return Request{}, a.lines.bad("want start and count")
+		}
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return Request{}, a.lines.bad("bad start block " + fields[0])
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || count < 0 {
+			return Request{}, a.lines.bad("bad block count " + fields[1])
+		}
+		a.next, a.left = start, count
+	}
+	block := a.next
+	a.next++
+	a.left--
+	return Request{Key: strconv.FormatInt(block, 10), Op: Get}, nil
+}
+
+type spcReader struct {
+	lines *lines
+	clock clock
+}
+
+func (s *spcReader) Read() (Request, error) {
+	line, err := s.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := splitCSV(line)
+	if len(fields) < 5 {
+		return Request{}, s.lines.bad("want asu,lba,size,opcode,timestamp")
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Request{}, s.lines.bad("bad size " + fields[2])
+	}
+	seconds, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return Request{}, s.lines.bad("bad timestamp " + fields[4])
+	}
+	return Request{
+		Timestamp: s.clock.since(int64(math.Round(seconds*1e6)), time.Microsecond),
+		Key:       fields[0] + ":" + fields[1],
+		Size:      size,
+		Op:        readOrWrite(fields[3]),
+	}, nil
+}
+
+type msrReader struct {
+	lines *lines
+	clock clock
+}
+
+func (m *msrReader) Read() (Request, error) {
+	line, err := m.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := splitCSV(line)
+	if len(fields) < 6 {
+		return Request{}, m.lines.bad("want timestamp,host,disk,type,offset,size")
+	}
+	ticks, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Request{}, m.lines.bad("bad timestamp " + fields[0])
+	}
+	size, err := strconv.Atoi(fields[5])
+	if err != nil {
+		return Request{}, m.lines.bad("bad size " + fields[5])
+	}
+	return Request{
+		Timestamp: m.clock.since(ticks, 100*time.Nanosecond),
+		Key:       fields[1] + ":" + fields[2] + ":" + fields[4],
+		Size:      size,
+		Op:        readOrWrite(fields[3]),
+	}, nil
+}
+
+type twitterReader struct {
+	lines *lines
+	clock clock
+}
+
+func (t *twitterReader) Read() (Request, error) {
+	line, err := t.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := splitCSV(line)
+	if len(fields) < 6 {
+		return Request{}, t.lines.bad("want timestamp,key,key_size,value_size,client,op")
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Request{}, t.lines.bad("bad timestamp " + fields[0])
+	}
+	keySize, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Request{}, t.lines.bad("bad key size " + fields[2])
+	}
+	valueSize, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return Request{}, t.lines.bad("bad value size " + fields[3])
+	}
+	return Request{
+		Timestamp: t.clock.since(seconds, time.Second),
+		Key:       fields[1],
+		Size:      keySize + valueSize,
+		Op:        twitterOp(fields[5]),
+	}, nil
+}
+
+type fiuReader struct {
+	lines *lines
+	clock clock
+}
+
+func (f *fiuReader) Read() (Request, error) {
+	line, err := f.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 6 {
+		return Request{}, f.lines.bad("want timestamp pid process lba blocks op")
+	}
+	nanos, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Request{}, f.lines.bad("bad timestamp " + fields[0])
+	}
+	blocks, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return Request{}, f.lines.bad("bad block count " + fields[4])
+	}
+	return Request{
+		Timestamp: f.clock.since(nanos, time.Nanosecond),
+		Key:       fields[3],
+		Size:      blocks * sectorSize,
+		Op:        readOrWrite(fields[5]),
+	}, nil
+}
+
+/*recordingReader reads a server's recording of its own traffic:
+its keyed requests, with the value of every set, and its flushes
+and resets.  A not found fetch replays as a fetch like any other*/
+type recordingReader struct {
+	reader *csv.Reader
+	clock  clock
+}
+
+func (r *recordingReader) Read() (Request, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		return Request{}, err
+	}
+	if len(row) < 6 {
+		line, _ := r.reader.FieldPos(0)
+		return Request{}, errors.New("line " + strconv.Itoa(line) + ": want timestamp_us,conn,command,key,result,cost")
+	}
+	micros, err := strconv.ParseInt(row[0], 10, 64)
+	if err != nil {
+		line, _ := r.reader.FieldPos(0)
+		return Request{}, errors.New("line " + strconv.Itoa(line) + ": bad timestamp " + row[0])
+	}
+	request := Request{
+		Timestamp: r.clock.since(micros, time.Microsecond),
+		Key:       row[3],
+		Op:        recordedOp(row[2]),
+	}
+	if len(row) > 6 {
+		request.Value, err = strconv.Unquote(row[6])
+		if err != nil {
+			line, _ := r.reader.FieldPos(6)
+			return Request{}, errors.New("line " + strconv.Itoa(line) + ": bad value " + row[6])
+		}
+		request.Size = len(request.Value)
+	}
+	return request, nil
+}
+
+func (l *lines) bad(problem string) error {
+	return errors.New("line " + strconv.Itoa(l.number) + ": " + problem)
+}
+
+func splitCSV(line string) []string {
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+/*readOrWrite normalizes the block traces' R/W, Read/Write*/
+func readOrWrite(op string) string {
+	if strings.HasPrefix(strings.ToUpper(op), "W") {
+		return Set
+	}
+	return Get
+}
+
+/*twitterOp folds twemcache's commands into get, set and delete*/
+func twitterOp(op string) string {
+	if op == "get" || op == "gets" {
+		return Get
+	} else if op == "delete" {
+		return Delete
+	}
+	return Set
+}
+
+/*recordedOp folds the commands of every protocol the server
+speaks into get, set and delete*/
+func recordedOp(command string) string {
+	if command == "set" {
+		return Set
+	} else if command == "delete" || command == "del" {
+		return Delete
+	} else if command == "flush_all" {
+		return Flush
+	} else if command == "reset" {
+		return Reset
+	}
+	return Get
+}
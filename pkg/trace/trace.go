@@ -0,0 +1,224 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+/*Request is one request of a trace, whatever format it came in.
+Timestamp is the time since the first request of the file, and
+stays 0 for formats that don't record time.  Size is in bytes,
+0 when the format doesn't say.  Value is what a set stored, which
+only recordings keep*/
+type Request struct {
+	Timestamp time.Duration
+	Key       string
+	Size      int
+	Op        string
+	Value     string
+}
+
+/*The operations a request's Op is normalized to.  Flush and Reset
+only come from recordings, and aren't requests for a key but the
+server emptying its cache (and for Reset, zeroing its counters)*/
+const (
+	Get    = "get"
+	Set    = "set"
+	Delete = "delete"
+	Flush  = "flush"
+	Reset  = "reset"
+)
+
+/*IsEvent is true for a recorded flush or reset, which happened to
+the whole cache rather than to any one key*/
+func (r Request) IsEvent() bool {
+	return r.Op == Flush || r.Op == Reset
+}
+
+/*Reader hands out a trace's requests in order, returning io.EOF
+after the last one*/
+type Reader interface {
+	Read() (Request, error)
+}
+
+/*Formats lists the trace formats NewReader understands*/
+var Formats = []string{"keys", "arc", "spc", "msr", "twitter", "fiu", "recording", "binary"}
+
+/*NewReader reads a trace in the named format:
+
+  keys:    our own CSV, one key per row in the first column and
+           optionally its time in seconds in the second
+  arc:     ARC block traces, "start count ignored request_number",
+           one request per block of the run
+  spc:     UMass/SPC block traces, "asu,lba,size,opcode,timestamp"
+  msr:     MSR Cambridge, "timestamp,host,disk,type,offset,size,response_time"
+  twitter: Twitter cache traces, "timestamp,key,key_size,value_size,client,op,ttl"
+  fiu:     FIU traces as used by LeCaR and CACHEUS,
+           "timestamp pid process lba blocks op major minor md5"
+  recording: what a server's -record_file captured,
+           "timestamp_us,conn,command,key,result,cost[,quoted value]"
+  binary:  our compact varint encoding, see binaryReader*/
+func NewReader(r io.Reader, format string) (Reader, error) {
+	if format == "" || format == "keys" {
+		return &keysReader{reader: csv.NewReader(r)}, nil
+	} else if format == "arc" {
+		return &arcReader{lines: newLines(r)}, nil
+	} else if format == "spc" {
+		return &spcReader{lines: newLines(r)}, nil
+	} else if format == "msr" {
+		return &msrReader{lines: newLines(r)}, nil
+	} else if format == "twitter" {
+		return &twitterReader{lines: newLines(r)}, nil
+	} else if format == "fiu" {
+		return &fiuReader{lines: newLines(r)}, nil
+	} else if format == "recording" {
+		reader := csv.NewReader(r)
+		reader.Comment = '#'
+		// only sets have the value column
+		reader.FieldsPerRecord = -1
+		return &recordingReader{reader: reader}, nil
+	} else if format == "binary" {
+		return newBinaryReader(r)
+	}
+	return nil, errors.New("Unknown trace format: " + format + " (one of " + strings.Join(Formats, ", ") + ")")
+}
+
+/*File is a trace read from disk or stdin, streamed a request at a
+time so there's no limit on its length*/
+type File struct {
+	Reader
+	file       *os.File
+	decompress func() error
+}
+
+/*Open starts reading a trace file in the given format, or stdin if
+the name is "-".  Gzip and zstd compressed traces are recognized
+by their contents and decompressed on the way, and so are binary
+traces, whatever format was asked for*/
+func Open(filename string, format string) (*File, error) {
+	file := os.Stdin
+	if filename != "-" {
+		var err error
+		file, err = os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f := &File{file: file}
+	r, closer, err := decompress(file)
+	if err == nil {
+		f.decompress = closer
+		if magic, _ := r.Peek(len(binaryMagic)); bytes.HasPrefix(magic, binaryMagic[:4]) {
+			format = "binary"
+		}
+		f.Reader, err = NewReader(r, format)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+/*Close closes the file underneath, but leaves stdin open*/
+func (f *File) Close() error {
+	var err error
+	if f.decompress != nil {
+		err = f.decompress()
+	}
+	if f.file == os.Stdin {
+		return err
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*Each calls fn with every request of the trace files in order,
+leaving out a recording's flushes and resets*/
+func Each(filenames []string, format string, fn func(Request) error) error {
+	return EachWithEvents(filenames, format, func(request Request) error {
+		if request.IsEvent() {
+			return nil
+		}
+		return fn(request)
+	})
+}
+
+/*EachWithEvents is Each for the simulator, which replays a
+recording's flushes and resets along with its requests*/
+func EachWithEvents(filenames []string, format string, fn func(Request) error) error {
+	for _, filename := range filenames {
+		file, err := Open(filename, format)
+		if err != nil {
+			return err
+		}
+		for {
+			request, err := file.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return errors.New(filename + ": " + err.Error())
+			}
+			err = fn(request)
+			if err != nil {
+				file.Close()
+				return err
+			}
+		}
+		file.Close()
+	}
+	return nil
+}
+
+/*lines hands out the non-blank lines of a text trace, keeping
+count so errors can say where they happened*/
+type lines struct {
+	scanner *bufio.Scanner
+	number  int
+}
+
+func newLines(r io.Reader) *lines {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &lines{scanner: scanner}
+}
+
+func (l *lines) next() (string, error) {
+	for l.scanner.Scan() {
+		l.number++
+		line := strings.TrimSpace(l.scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	if err := l.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+/*clock turns a format's absolute timestamps, counted in some unit,
+into time since the first request.  The difference is taken before
+scaling since MSR's 100ns ticks since 1601 overflow a Duration*/
+type clock struct {
+	started bool
+	first   int64
+}
+
+func (c *clock) since(at int64, unit time.Duration) time.Duration {
+	if !c.started {
+		c.started = true
+		c.first = at
+	}
+	return time.Duration(at-c.first) * unit
+}
@@ -0,0 +1,180 @@
+package trace
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*readAll reads a whole trace, stopping at the first error*/
+func readAll(r Reader) ([]Request, error) {
+	requests := []Request{}
+	for {
+		request, err := r.Read()
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+	}
+}
+
+func TestSampleTraces(t *testing.T) {
+	cases := []struct {
+		file     string
+		format   string
+		requests int
+		unique   int
+		sets     int
+		first    Request
+		last     Request
+	}{
+		{"sample.arc", "arc", 1093, 607, 0,
+			Request{Key: "1080", Op: Get},
+			Request{Key: "1640", Op: Get}},
+		{"sample.spc", "spc", 400, 216, 97,
+			Request{Key: "1:20064", Size: 8192, Op: Get},
+			Request{Timestamp: 1803334 * time.Microsecond, Key: "1:20000", Size: 8192, Op: Get}},
+		{"sample_msr.csv", "msr", 400, 216, 81,
+			Request{Key: "src1:0:905216", Size: 4096, Op: Get},
+			Request{Timestamp: 18864228 * 100 * time.Nanosecond, Key: "src1:1:0", Size: 65536, Op: Get}},
+		{"sample_twitter.csv", "twitter", 400, 166, 57,
+			Request{Key: "ec8956637a99787b", Size: 16 + 355, Op: Get},
+			Request{Timestamp: 29 * time.Second, Key: "43ec517d68b6edd3", Size: 16 + 507, Op: Get}},
+		{"sample_fiu.trace", "fiu", 400, 167, 102,
+			Request{Key: "30000888", Size: 8 * sectorSize, Op: Get},
+			Request{Timestamp: 1937989254, Key: "30000000", Size: 8 * sectorSize, Op: Get}},
+	}
+	for _, c := range cases {
+		file, err := Open("../../data/traces/"+c.file, c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests, err := readAll(file)
+		file.Close()
+		if err != nil {
+			t.Errorf("%s: %v", c.file, err)
+			continue
+		}
+		keys := map[string]bool{}
+		sets := 0
+		for _, request := range requests {
+			keys[request.Key] = true
+			if request.Op == Set {
+				sets++
+			}
+		}
+		if len(requests) != c.requests || len(keys) != c.unique || sets != c.sets {
+			t.Errorf("%s: got %d requests, %d keys, %d sets, want %d, %d, %d",
+				c.file, len(requests), len(keys), sets, c.requests, c.unique, c.sets)
+			continue
+		}
+		if requests[0] != c.first {
+			t.Errorf("%s: first request %+v, want %+v", c.file, requests[0], c.first)
+		}
+		if requests[len(requests)-1] != c.last {
+			t.Errorf("%s: last request %+v, want %+v", c.file, requests[len(requests)-1], c.last)
+		}
+	}
+}
+
+func TestReaders(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   string
+		input    string
+		requests []Request
+		err      string
+	}{
+		{"keys", "keys", "key1\nkey2\n", []Request{{Key: "key1", Op: Get}, {Key: "key2", Op: Get}}, ""},
+		{"keys with times", "keys", "key1,1.5\nkey2,2\n",
+			[]Request{{Key: "key1", Op: Get}, {Timestamp: 500 * time.Millisecond, Key: "key2", Op: Get}}, ""},
+		{"keys bad timestamp", "keys", "key1,soon\n", nil, "line 1: bad timestamp soon"},
+		{"arc runs", "arc", "# comment\n\n10 2 0 0\n5 1 0 1\n",
+			[]Request{{Key: "10", Op: Get}, {Key: "11", Op: Get}, {Key: "5", Op: Get}}, ""},
+		{"arc empty run", "arc", "10 0 0 0\n3 1 0 1\n", []Request{{Key: "3", Op: Get}}, ""},
+		{"arc short line", "arc", "10\n", nil, "line 1: want start and count"},
+		{"arc bad count", "arc", "10 -1 0 0\n", nil, "line 1: bad block count -1"},
+		{"spc", "spc", "0,100,4096,W,0.5\n0,100,4096,r,1.5\n",
+			[]Request{{Key: "0:100", Size: 4096, Op: Set}, {Timestamp: time.Second, Key: "0:100", Size: 4096, Op: Get}}, ""},
+		{"spc short line", "spc", "0,100,4096\n", nil, "line 1: want asu,lba,size,opcode,timestamp"},
+		{"spc bad size", "spc", "0,100,big,r,0\n", nil, "line 1: bad size big"},
+		{"msr", "msr", "100,web,2,Write,512,4096,10\n",
+			[]Request{{Key: "web:2:512", Size: 4096, Op: Set}}, ""},
+		{"msr bad timestamp", "msr", "x,web,2,Write,512,4096,10\n", nil, "line 1: bad timestamp x"},
+		{"twitter ops", "twitter", "5,a,1,10,0,gets,0\n6,a,1,10,0,add,0\n7,a,1,10,0,delete,0\n",
+			[]Request{{Key: "a", Size: 11, Op: Get}, {Timestamp: time.Second, Key: "a", Size: 11, Op: Set}, {Timestamp: 2 * time.Second, Key: "a", Size: 11, Op: Delete}}, ""},
+		{"twitter bad value size", "twitter", "5,a,1,x,0,get,0\n", nil, "line 1: bad value size x"},
+		{"fiu", "fiu", "1000 1 bash 77 2 W 8 0 abc\n",
+			[]Request{{Key: "77", Size: 2 * sectorSize, Op: Set}}, ""},
+		{"recording", "recording", "# header\n10,1,get,key1,M,5\n20,1,set,key1,-,5,\"\"\"a,b\"\"\"\n30,2,flush_all,,-,0\n40,2,reset,,-,0\n50,3,del,key1,-,0\n",
+			[]Request{{Key: "key1", Op: Get}, {Timestamp: 10 * time.Microsecond, Key: "key1", Size: 3, Op: Set, Value: "a,b"},
+				{Timestamp: 20 * time.Microsecond, Op: Flush}, {Timestamp: 30 * time.Microsecond, Op: Reset},
+				{Timestamp: 40 * time.Microsecond, Key: "key1", Op: Delete}}, ""},
+		{"recording short row", "recording", "10,1,get\n", nil, "line 1: want timestamp_us,conn,command,key,result,cost"},
+		{"recording bad value", "recording", "10,1,set,key1,-,5,plain\n", nil, "line 1: bad value plain"},
+		{"fiu error line number", "fiu", "1000 1 bash 77 2 W 8 0 abc\n\n1001 1 bash\n", nil, "line 3: want timestamp pid process lba blocks op"},
+	}
+	for _, c := range cases {
+		reader, err := NewReader(strings.NewReader(c.input), c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests, err := readAll(reader)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(requests) != len(c.requests) {
+			t.Errorf("%s: got %+v, want %+v", c.name, requests, c.requests)
+			continue
+		}
+		for i := range requests {
+			if requests[i] != c.requests[i] {
+				t.Errorf("%s: request %d is %+v, want %+v", c.name, i, requests[i], c.requests[i])
+			}
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewReader(strings.NewReader(""), "parquet")
+	if err == nil || !strings.HasPrefix(err.Error(), "Unknown trace format: parquet") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestEachSkipsEvents(t *testing.T) {
+	filename := t.TempDir() + "/recording.csv"
+	err := ioutil.WriteFile(filename, []byte("10,1,get,key1,M,5\n20,1,flush_all,,-,0\n30,1,get,key1,M,5\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(each func([]string, string, func(Request) error) error) int {
+		requests := 0
+		err := each([]string{filename}, "recording", func(Request) error {
+			requests++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return requests
+	}
+	if requests := count(Each); requests != 2 {
+		t.Errorf("Each gave %d requests, want 2", requests)
+	}
+	if requests := count(EachWithEvents); requests != 3 {
+		t.Errorf("EachWithEvents gave %d requests, want 3", requests)
+	}
+}
@@ -0,0 +1,158 @@
+package tracegen
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+/*zipfSampler draws ranks 1..n with probability proportional to
+1/rank^alpha, using Hörmann and Derflinger's rejection-inversion
+method.  Unlike math/rand's Zipf it takes any alpha >= 0, including
+the YCSB default of 0.99, and needs no table the size of the key
+space*/
+type zipfSampler struct {
+	rng         *rand.Rand
+	n           float64
+	alpha       float64
+	hIntegralX1 float64
+	hIntegralN  float64
+	s           float64
+}
+
+func newZipfSampler(rng *rand.Rand, n int, alpha float64) *zipfSampler {
+	z := &zipfSampler{rng: rng, n: float64(n), alpha: alpha}
+	z.hIntegralX1 = z.hIntegral(1.5) - 1
+	z.hIntegralN = z.hIntegral(z.n + 0.5)
+	z.s = 2 - z.hIntegralInverse(z.hIntegral(2.5)-z.h(2))
+	return z
+}
+
+func (z *zipfSampler) rank() int {
+	for {
+		u := z.hIntegralN + z.rng.Float64()*(z.hIntegralX1-z.hIntegralN)
+		x := z.hIntegralInverse(u)
+		k := math.Floor(x + 0.5)
+		if k < 1 {
+			k = 1
+		} else if k > z.n {
+			k = z.n
+		}
+		if k-x <= z.s || u >= z.hIntegral(k+0.5)-z.h(k) {
+			return int(k)
+		}
+	}
+}
+
+func (z *zipfSampler) h(x float64) float64 {
+	return math.Exp(-z.alpha * math.Log(x))
+}
+
+func (z *zipfSampler) hIntegral(x float64) float64 {
+	logX := math.Log(x)
+	return expm1OverX((1-z.alpha)*logX) * logX
+}
+
+func (z *zipfSampler) hIntegralInverse(x float64) float64 {
+	t := x * (1 - z.alpha)
+	if t < -1 {
+		t = -1
+	}
+	return math.Exp(log1pOverX(t) * x)
+}
+
+func log1pOverX(x float64) float64 {
+	if math.Abs(x) > 1e-8 {
+		return math.Log1p(x) / x
+	}
+	return 1 - x*(0.5-x*(1.0/3.0-0.25*x))
+}
+
+func expm1OverX(x float64) float64 {
+	if math.Abs(x) > 1e-8 {
+		return math.Expm1(x) / x
+	}
+	return 1 + x*0.5*(1+x*(1.0/3.0)*(1+0.25*x))
+}
+
+func newZipf(params Params, rng *rand.Rand) (Generator, error) {
+	alpha := orDefaultSet(params.Alpha, 0.99)
+	if alpha < 0 {
+		return nil, errors.New("Alpha can't be negative")
+	}
+	sampler := newZipfSampler(rng, params.MaxKey, alpha)
+	return generatorFunc(func() int { return sampler.rank() - 1 }), nil
+}
+
+/*newScrambledZipf has the same popularity skew as zipf, but hashes
+each rank so the popular keys are scattered across the key space
+instead of bunched at the low numbers*/
+func newScrambledZipf(params Params, rng *rand.Rand) (Generator, error) {
+	alpha := orDefaultSet(params.Alpha, 0.99)
+	if alpha < 0 {
+		return nil, errors.New("Alpha can't be negative")
+	}
+	sampler := newZipfSampler(rng, params.MaxKey, alpha)
+	maxKey := uint64(params.MaxKey)
+	return generatorFunc(func() int {
+		hash := fnv.New64a()
+		hash.Write([]byte(strconv.Itoa(sampler.rank())))
+		return int(hash.Sum64() % maxKey)
+	}), nil
+}
+
+/*newHotspot sends HotProb of the requests to the first HotFraction
+of the keys, uniformly within the hot and cold parts*/
+func newHotspot(params Params, rng *rand.Rand) (Generator, error) {
+	hotFraction := orDefaultSet(params.HotFraction, 0.2)
+	hotProb := orDefaultSet(params.HotProb, 0.8)
+	if hotFraction < 0 || hotFraction > 1 || hotProb < 0 || hotProb > 1 {
+		return nil, errors.New("Hot fraction and probability must be between 0 and 1")
+	}
+	hotKeys := int(float64(params.MaxKey) * hotFraction)
+	if hotKeys < 1 {
+		hotKeys = 1
+	}
+	coldKeys := params.MaxKey - hotKeys
+	return generatorFunc(func() int {
+		if coldKeys == 0 || rng.Float64() < hotProb {
+			return rng.Intn(hotKeys)
+		}
+		return hotKeys + rng.Intn(coldKeys)
+	}), nil
+}
+
+/*newLatest is YCSB's "latest": the key space is treated as records
+in insertion order, and requests favor the most recently inserted
+ones along a zipf curve.  Each request inserts a new record with
+InsertProb, wrapping around to reuse the oldest key once the key
+space is used up*/
+func newLatest(params Params, rng *rand.Rand) (Generator, error) {
+	alpha := orDefaultSet(params.Alpha, 0.99)
+	if alpha < 0 {
+		return nil, errors.New("Alpha can't be negative")
+	}
+	sampler := newZipfSampler(rng, params.MaxKey, alpha)
+	insertProb := orDefaultSet(params.InsertProb, 0.05)
+	latest := params.MaxKey - 1
+	return generatorFunc(func() int {
+		if rng.Float64() < insertProb {
+			latest = (latest + 1) % params.MaxKey
+			return latest
+		}
+		return (latest - (sampler.rank() - 1) + params.MaxKey) % params.MaxKey
+	}), nil
+}
+
+func newUniform(params Params, rng *rand.Rand) (Generator, error) {
+	return generatorFunc(func() int { return rng.Intn(params.MaxKey) }), nil
+}
+
+/*generatorFunc lets a closure serve as a Generator*/
+type generatorFunc func() int
+
+func (f generatorFunc) Next() int {
+	return f()
+}
@@ -0,0 +1,141 @@
+package tracegen
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestZipfRanks(t *testing.T) {
+	for _, alpha := range []float64{0, 0.5, 0.99, 1, 1.5} {
+		sampler := newZipfSampler(rand.New(rand.NewSource(1)), 100, alpha)
+		counts := make([]int, 101)
+		draws := 200000
+		for i := 0; i < draws; i++ {
+			counts[sampler.rank()]++
+		}
+		norm := 0.0
+		for rank := 1; rank <= 100; rank++ {
+			norm += math.Pow(float64(rank), -alpha)
+		}
+		for _, rank := range []int{1, 2, 5, 20, 100} {
+			want := math.Pow(float64(rank), -alpha) / norm
+			got := float64(counts[rank]) / float64(draws)
+			// three standard errors of a binomial proportion
+			if math.Abs(got-want) > 3*math.Sqrt(want*(1-want)/float64(draws))+1e-4 {
+				t.Errorf("alpha %v: rank %d drawn %.4f of the time, want %.4f", alpha, rank, got, want)
+			}
+		}
+		if counts[0] != 0 {
+			t.Errorf("alpha %v: drew rank 0", alpha)
+		}
+	}
+}
+
+/*share is the fraction of keys in [from, to)*/
+func share(keys []int, from int, to int) float64 {
+	in := 0
+	for _, key := range keys {
+		if key >= from && key < to {
+			in++
+		}
+	}
+	return float64(in) / float64(len(keys))
+}
+
+func TestZipfParams(t *testing.T) {
+	// nil is the default 0.99, while an explicit 0 is uniform
+	skewed := generate(t, "ZIPF", Params{MaxKey: 1000}, 1, 50000)
+	if got := share(skewed, 0, 10); got < 0.35 {
+		t.Errorf("the first 1%% of keys got %.3f of the default zipf requests", got)
+	}
+	uniform := generate(t, "ZIPF", Params{MaxKey: 1000, Alpha: float(0)}, 1, 50000)
+	if got := share(uniform, 0, 10); got < 0.008 || got > 0.012 {
+		t.Errorf("the first 1%% of keys got %.3f of the alpha=0 requests", got)
+	}
+	scrambled := generate(t, "SCRAMBLED_ZIPF", Params{MaxKey: 1000}, 1, 50000)
+	if got := share(scrambled, 0, 10); got > 0.1 {
+		t.Errorf("scrambling left %.3f of requests on the first 1%% of keys", got)
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	cases := []struct {
+		params Params
+		hot    int
+		want   float64
+	}{
+		{Params{MaxKey: 1000}, 200, 0.8},
+		{Params{MaxKey: 1000, HotFraction: float(0.1), HotProb: float(0.5)}, 100, 0.5},
+		{Params{MaxKey: 1000, HotProb: float(0)}, 200, 0},
+		{Params{MaxKey: 1000, HotProb: float(1)}, 200, 1},
+		// every key hot leaves nothing cold to send requests to
+		{Params{MaxKey: 1000, HotFraction: float(1), HotProb: float(0)}, 1000, 1},
+	}
+	for _, c := range cases {
+		keys := generate(t, "HOTSPOT", c.params, 1, 50000)
+		if got := share(keys, 0, c.hot); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("%+v: %.3f of requests went to the first %d keys, want %.3f", c.params, got, c.hot, c.want)
+		}
+	}
+}
+
+/*inserts counts the requests that insert the next key, which with
+a key space bigger than the trace are the keys counting up from 0*/
+func inserts(keys []int) int {
+	next, count := 0, 0
+	for _, key := range keys {
+		if key == next {
+			next++
+			count++
+		}
+	}
+	return count
+}
+
+func TestLatestInsertRate(t *testing.T) {
+	cases := []struct {
+		insertProb *float64
+		want       float64
+	}{
+		{nil, 0.05},
+		{float(0.2), 0.2},
+		{float(0), 0},
+		{float(1), 1},
+	}
+	for _, c := range cases {
+		keys := generate(t, "LATEST", Params{MaxKey: 1000000, InsertProb: c.insertProb}, 1, 50000)
+		if got := float64(inserts(keys)) / float64(len(keys)); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("insert_prob %v: inserted on %.3f of requests, want %.3f", c.insertProb, got, c.want)
+		}
+	}
+	// without inserts the newest key, the last one, is the most popular
+	keys := generate(t, "LATEST", Params{MaxKey: 1000, InsertProb: float(0)}, 1, 50000)
+	if got := share(keys, 999, 1000); got < 0.1 {
+		t.Errorf("the latest key got %.3f of requests", got)
+	}
+}
+
+func TestDistributionErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		params Params
+		err    string
+	}{
+		{"ZIPF", Params{MaxKey: 10, Alpha: float(-1)}, "Alpha can't be negative"},
+		{"SCRAMBLED_ZIPF", Params{MaxKey: 10, Alpha: float(-1)}, "Alpha can't be negative"},
+		{"LATEST", Params{MaxKey: 10, Alpha: float(-1)}, "Alpha can't be negative"},
+		{"HOTSPOT", Params{MaxKey: 10, HotProb: float(1.5)}, "Hot fraction and probability must be between 0 and 1"},
+		{"HOTSPOT", Params{MaxKey: 10, HotFraction: float(-0.1)}, "Hot fraction and probability must be between 0 and 1"},
+	}
+	for _, c := range cases {
+		_, err := New(c.name, c.params, rand.New(rand.NewSource(1)))
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
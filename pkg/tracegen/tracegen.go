@@ -0,0 +1,399 @@
+package tracegen
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+/*Generator produces a stream of key numbers, one per request.
+Traces write them out as key<n>*/
+type Generator interface {
+	Next() int
+}
+
+/*Params tunes the generators.  Anything left zero falls back
+to the value data/key_generator.py hard codes for that generator,
+or YCSB's default for the distributions it doesn't have.  The
+newer distributions' parameters are pointers instead, since 0 is a
+setting they can take: nil is what falls back to the default*/
+type Params struct {
+	// keys are numbered 0 to MaxKey-1
+	MaxKey int `json:"-"`
+	// standard deviation of the LRU random walk
+	Sigma float64 `json:"sigma"`
+	// bounds on where the LRU walk's center can wander
+	WalkMin int `json:"walk_min"`
+	WalkMax int `json:"walk_max"`
+	// size of the frequently used set, and the keys it's drawn from
+	FreqSetSize int `json:"freq_set_size"`
+	FreqRange   int `json:"freq_range"`
+	// size of the high cost set, drawn from the top CostFraction of keys
+	CostSetSize  int     `json:"cost_set_size"`
+	CostFraction float64 `json:"cost_fraction"`
+	// chance each request goes to the frequent or costly set
+	FreqProb float64 `json:"freq_prob"`
+	CostProb float64 `json:"cost_prob"`
+	// length of each scan run (LFU, LCR) or phase (LFU_SCAN, LCR_SCAN)
+	ScanLength int `json:"scan_length"`
+	// skew of the zipf curve for ZIPF, SCRAMBLED_ZIPF and LATEST
+	Alpha *float64 `json:"alpha"`
+	// HOTSPOT sends HotProb of requests to the first HotFraction of keys
+	HotFraction *float64 `json:"hot_fraction"`
+	HotProb     *float64 `json:"hot_prob"`
+	// chance each LATEST request inserts a new key
+	InsertProb *float64 `json:"insert_prob"`
+}
+
+/*Generators lists the names New accepts*/
+var Generators = []string{"LRU", "LFU", "LFU_SCAN", "LCR", "LCR_SCAN", "ZIPF", "SCRAMBLED_ZIPF", "HOTSPOT", "LATEST", "UNIFORM"}
+
+/*New builds the named generator*/
+func New(name string, params Params, rng *rand.Rand) (Generator, error) {
+	if params.MaxKey <= 0 {
+		return nil, errors.New("MaxKey must be positive")
+	}
+	if name == "LRU" {
+		return newRandomWalk(params, rng), nil
+	} else if name == "LFU" {
+		return newFrequentSet(params, rng)
+	} else if name == "LFU_SCAN" {
+		return newFrequentScan(params, rng)
+	} else if name == "LCR" {
+		return newCostSets(params, rng)
+	} else if name == "LCR_SCAN" {
+		return newCostScan(params, rng)
+	} else if name == "ZIPF" {
+		return newZipf(params, rng)
+	} else if name == "SCRAMBLED_ZIPF" {
+		return newScrambledZipf(params, rng)
+	} else if name == "HOTSPOT" {
+		return newHotspot(params, rng)
+	} else if name == "LATEST" {
+		return newLatest(params, rng)
+	} else if name == "UNIFORM" {
+		return newUniform(params, rng)
+	}
+	return nil, errors.New("Unknown generator: " + name)
+}
+
+func orDefault(value int, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+func orDefaultFloat(value float64, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+/*orDefaultSet is orDefaultFloat for the parameters that can be 0*/
+func orDefaultSet(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+func clamp(n int, min int, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+/*keySet is a set of distinct keys with a stable order to cycle through*/
+type keySet struct {
+	keys    []int
+	members map[int]bool
+}
+
+/*pickSet draws size distinct keys from [from, to)*/
+func pickSet(rng *rand.Rand, size int, from int, to int) (*keySet, error) {
+	if size > to-from {
+		return nil, errors.New("Can't pick " + strconv.Itoa(size) + " distinct keys from a range of " + strconv.Itoa(to-from))
+	}
+	set := &keySet{members: map[int]bool{}}
+	for len(set.keys) < size {
+		key := from + rng.Intn(to-from)
+		if !set.members[key] {
+			set.members[key] = true
+			set.keys = append(set.keys, key)
+		}
+	}
+	sort.Ints(set.keys)
+	return set, nil
+}
+
+func (ks *keySet) random(rng *rand.Rand) int {
+	return ks.keys[rng.Intn(len(ks.keys))]
+}
+
+/*complement picks keys outside some sets without ever building
+the (possibly huge) list of everything else*/
+type complement struct {
+	maxKey   int
+	excluded []*keySet
+	next     int
+}
+
+func newComplement(maxKey int, excluded ...*keySet) (*complement, error) {
+	c := &complement{maxKey: maxKey, excluded: excluded}
+	for key := 0; key < maxKey; key++ {
+		if !c.excludes(key) {
+			return c, nil
+		}
+	}
+	return nil, errors.New("No keys left outside the frequent and cost sets")
+}
+
+func (c *complement) excludes(key int) bool {
+	for _, set := range c.excluded {
+		if set.members[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *complement) random(rng *rand.Rand) int {
+	for {
+		key := rng.Intn(c.maxKey)
+		if !c.excludes(key) {
+			return key
+		}
+	}
+}
+
+/*sequential walks the complement in key order, wrapping around*/
+func (c *complement) sequential() int {
+	for {
+		key := c.next
+		c.next = (c.next + 1) % c.maxKey
+		if !c.excludes(key) {
+			return key
+		}
+	}
+}
+
+/*cycle steps through a set in order, wrapping around*/
+type cycle struct {
+	set   *keySet
+	index int
+}
+
+func (c *cycle) next() int {
+	key := c.set.keys[c.index]
+	c.index = (c.index + 1) % len(c.set.keys)
+	return key
+}
+
+/*randomWalk favors whatever keys are near a center that
+wanders around, which suits LRU*/
+type randomWalk struct {
+	rng     *rand.Rand
+	maxKey  int
+	sigma   float64
+	walkMin int
+	walkMax int
+	mean    int
+}
+
+func newRandomWalk(params Params, rng *rand.Rand) *randomWalk {
+	return &randomWalk{
+		rng:     rng,
+		maxKey:  params.MaxKey,
+		sigma:   orDefaultFloat(params.Sigma, 8),
+		walkMin: orDefault(params.WalkMin, 75),
+		walkMax: orDefault(params.WalkMax, params.MaxKey-25),
+		mean:    params.MaxKey / 2,
+	}
+}
+
+func (rw *randomWalk) sample(mean int, min int, max int) int {
+	return clamp(int(math.Round(rw.rng.NormFloat64()*rw.sigma+float64(mean))), min, max)
+}
+
+func (rw *randomWalk) Next() int {
+	key := rw.sample(rw.mean, 0, rw.maxKey-1)
+	rw.mean = rw.sample(rw.mean, rw.walkMin, rw.walkMax)
+	return key
+}
+
+/*frequentSet mostly picks from a small frequently used set, with
+occasional runs of random other keys long enough to flush an LRU*/
+type frequentSet struct {
+	rng      *rand.Rand
+	freq     *keySet
+	rest     *complement
+	freqProb float64
+	runLen   int
+	runLeft  int
+}
+
+func newFrequentSet(params Params, rng *rand.Rand) (*frequentSet, error) {
+	freq, err := pickSet(rng, orDefault(params.FreqSetSize, 200), 0, orDefault(params.FreqRange, params.MaxKey))
+	if err != nil {
+		return nil, err
+	}
+	rest, err := newComplement(params.MaxKey, freq)
+	if err != nil {
+		return nil, err
+	}
+	return &frequentSet{
+		rng:      rng,
+		freq:     freq,
+		rest:     rest,
+		freqProb: orDefaultFloat(params.FreqProb, 0.99),
+		runLen:   orDefault(params.ScanLength, 200),
+	}, nil
+}
+
+func (fs *frequentSet) Next() int {
+	if fs.runLeft == 0 && fs.rng.Float64() >= fs.freqProb {
+		fs.runLeft = fs.runLen
+	}
+	if fs.runLeft > 0 {
+		fs.runLeft--
+		return fs.rest.random(fs.rng)
+	}
+	return fs.freq.random(fs.rng)
+}
+
+/*phases warms up on some fixed sets, then rotates through
+phases of a fixed length, each served by its own source*/
+type phases struct {
+	warmup  []func() int
+	lengths []int
+	sources []func() int
+	length  int
+	phase   int
+	served  int
+	current int
+	step    int
+}
+
+func (p *phases) Next() int {
+	for p.phase < len(p.warmup) {
+		if p.served < p.lengths[p.phase] {
+			p.served++
+			return p.warmup[p.phase]()
+		}
+		p.phase++
+		p.served = 0
+	}
+	if p.step >= p.length {
+		p.step = 0
+		p.current = (p.current + 1) % len(p.sources)
+	}
+	p.step++
+	return p.sources[p.current]()
+}
+
+/*newFrequentScan cycles the frequent set three times, then
+alternates scans through every key with phases of the frequent
+set, which punishes LRU for forgetting the frequent keys*/
+func newFrequentScan(params Params, rng *rand.Rand) (*phases, error) {
+	freqSize := orDefault(params.FreqSetSize, 200)
+	freq, err := pickSet(rng, freqSize, 0, orDefault(params.FreqRange, params.MaxKey))
+	if err != nil {
+		return nil, err
+	}
+	freqCycle := &cycle{set: freq}
+	all := &complement{maxKey: params.MaxKey}
+	return &phases{
+		warmup:  []func() int{freqCycle.next},
+		lengths: []int{freqSize * 3},
+		sources: []func() int{all.sequential, freqCycle.next},
+		length:  orDefault(params.ScanLength, 250),
+	}, nil
+}
+
+/*costSets picks cheap frequent keys and expensive keys from the
+top of the key range about equally often, with occasional scan
+runs of everything else*/
+type costSets struct {
+	frequentSet
+	cost     *keySet
+	costProb float64
+}
+
+func pickCostSets(params Params, rng *rand.Rand) (*keySet, *keySet, error) {
+	freq, err := pickSet(rng, orDefault(params.FreqSetSize, 250), 0, orDefault(params.FreqRange, 1000))
+	if err != nil {
+		return nil, nil, err
+	}
+	costFrom := params.MaxKey - int(float64(params.MaxKey)*orDefaultFloat(params.CostFraction, 0.1))
+	cost, err := pickSet(rng, orDefault(params.CostSetSize, 250), costFrom, params.MaxKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return freq, cost, nil
+}
+
+func newCostSets(params Params, rng *rand.Rand) (*costSets, error) {
+	freq, cost, err := pickCostSets(params, rng)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := newComplement(params.MaxKey, freq, cost)
+	if err != nil {
+		return nil, err
+	}
+	return &costSets{
+		frequentSet: frequentSet{
+			rng:      rng,
+			freq:     freq,
+			rest:     rest,
+			freqProb: orDefaultFloat(params.FreqProb, 0.495),
+			runLen:   orDefault(params.ScanLength, 200),
+		},
+		cost:     cost,
+		costProb: orDefaultFloat(params.CostProb, 0.495),
+	}, nil
+}
+
+func (cs *costSets) Next() int {
+	if cs.runLeft == 0 {
+		roll := cs.rng.Float64()
+		if roll < cs.freqProb {
+			return cs.freq.random(cs.rng)
+		} else if roll < cs.freqProb+cs.costProb {
+			return cs.cost.random(cs.rng)
+		}
+		cs.runLeft = cs.runLen
+	}
+	cs.runLeft--
+	return cs.rest.random(cs.rng)
+}
+
+/*newCostScan cycles the frequent set and then the cost set three
+times each, then rotates through phases of scanning everything
+else, the frequent set and the cost set*/
+func newCostScan(params Params, rng *rand.Rand) (*phases, error) {
+	freq, cost, err := pickCostSets(params, rng)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := newComplement(params.MaxKey, freq, cost)
+	if err != nil {
+		return nil, err
+	}
+	freqCycle := &cycle{set: freq}
+	costCycle := &cycle{set: cost}
+	return &phases{
+		warmup:  []func() int{freqCycle.next, costCycle.next},
+		lengths: []int{len(freq.keys) * 3, len(cost.keys) * 3},
+		sources: []func() int{rest.sequential, freqCycle.next, costCycle.next},
+		length:  orDefault(params.ScanLength, 250),
+	}, nil
+}
@@ -0,0 +1,262 @@
+package tracegen
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func generate(t *testing.T, name string, params Params, seed int64, count int) []int {
+	generator, err := New(name, params, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	keys := make([]int, count)
+	for i := range keys {
+		keys[i] = generator.Next()
+	}
+	return keys
+}
+
+func TestSameSeedSameTrace(t *testing.T) {
+	for _, name := range Generators {
+		params := Params{MaxKey: 10000}
+		first := generate(t, name, params, 7, 5000)
+		if !reflect.DeepEqual(first, generate(t, name, params, 7, 5000)) {
+			t.Errorf("%s: seed 7 gave two different traces", name)
+		}
+		if reflect.DeepEqual(first, generate(t, name, params, 8, 5000)) {
+			t.Errorf("%s: seeds 7 and 8 gave the same trace", name)
+		}
+	}
+}
+
+func TestKeysInRange(t *testing.T) {
+	for _, name := range Generators {
+		for _, key := range generate(t, name, Params{MaxKey: 10000}, 1, 20000) {
+			if key < 0 || key >= 10000 {
+				t.Errorf("%s: key %d outside [0, 10000)", name, key)
+				break
+			}
+		}
+	}
+}
+
+func TestRandomWalk(t *testing.T) {
+	keys := generate(t, "LRU", Params{MaxKey: 10000}, 1, 20000)
+	if keys[0] < 5000-50 || keys[0] > 5000+50 {
+		t.Errorf("the walk should start around the middle, got key %d", keys[0])
+	}
+	for i := 1; i < len(keys); i++ {
+		// a step of the center plus the spread around it, with sigma 8
+		if math.Abs(float64(keys[i]-keys[i-1])) > 100 {
+			t.Fatalf("the walk jumped from %d to %d", keys[i-1], keys[i])
+		}
+	}
+	for _, key := range generate(t, "LRU", Params{MaxKey: 200, Sigma: 1000, WalkMin: 50, WalkMax: 150}, 1, 2000) {
+		if key < 0 || key >= 200 {
+			t.Fatalf("a wide walk went out to %d", key)
+		}
+	}
+}
+
+/*members collects the distinct keys*/
+func members(keys []int) map[int]bool {
+	set := map[int]bool{}
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+/*scanRuns is the length of each run of keys outside the sets,
+leaving out a run cut short by the end of the trace*/
+func scanRuns(keys []int, sets ...map[int]bool) []int {
+	runs := []int{}
+	run := 0
+	for _, key := range keys {
+		inSet := false
+		for _, set := range sets {
+			inSet = inSet || set[key]
+		}
+		if !inSet {
+			run++
+		} else if run > 0 {
+			runs = append(runs, run)
+			run = 0
+		}
+	}
+	return runs
+}
+
+func TestFrequentSet(t *testing.T) {
+	// with a 1% chance of a 200 key run each time, about two thirds
+	// of the requests are runs, as in generate_lfu_keys
+	rng := rand.New(rand.NewSource(3))
+	fs, err := newFrequentSet(Params{MaxKey: 10000}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.freq.keys) != 200 {
+		t.Fatalf("the frequent set has %d keys, want 200", len(fs.freq.keys))
+	}
+	keys := make([]int, 100000)
+	inFreq := 0
+	for i := range keys {
+		keys[i] = fs.Next()
+		if fs.freq.members[keys[i]] {
+			inFreq++
+		}
+	}
+	share := float64(inFreq) / float64(len(keys))
+	if share < 0.28 || share > 0.38 {
+		t.Errorf("%.3f of requests went to the frequent set, want about a third", share)
+	}
+	for _, run := range scanRuns(keys, fs.freq.members) {
+		if run%200 != 0 {
+			t.Fatalf("got a scan run of %d keys, runs are 200 long", run)
+		}
+	}
+}
+
+func TestCostSets(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	cs, err := newCostSets(Params{MaxKey: 10000}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range cs.freq.keys {
+		if key >= 1000 {
+			t.Fatalf("frequent key %d, they come from the first 1000", key)
+		}
+	}
+	for _, key := range cs.cost.keys {
+		if key < 9000 {
+			t.Fatalf("cost key %d, they come from the top tenth", key)
+		}
+	}
+	keys := make([]int, 100000)
+	inFreq, inCost := 0, 0
+	for i := range keys {
+		keys[i] = cs.Next()
+		if cs.freq.members[keys[i]] {
+			inFreq++
+		} else if cs.cost.members[keys[i]] {
+			inCost++
+		}
+	}
+	// a 1% chance of a 200 key run leaves a third for the two sets
+	if ratio := float64(inFreq) / float64(inCost); ratio < 0.9 || ratio > 1.1 {
+		t.Errorf("%d frequent and %d cost requests, want about the same", inFreq, inCost)
+	}
+	if share := float64(inFreq+inCost) / float64(len(keys)); share < 0.28 || share > 0.38 {
+		t.Errorf("%.3f of requests went to the sets, want about a third", share)
+	}
+	for _, run := range scanRuns(keys, cs.freq.members, cs.cost.members) {
+		if run%200 != 0 {
+			t.Fatalf("got a scan run of %d keys, runs are 200 long", run)
+		}
+	}
+}
+
+func sorted(set map[int]bool) []int {
+	keys := []int{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+/*repeated is keys cycled through until there are count of them*/
+func repeated(keys []int, count int) []int {
+	out := []int{}
+	for len(out) < count {
+		out = append(out, keys[len(out)%len(keys)])
+	}
+	return out
+}
+
+func TestFrequentScan(t *testing.T) {
+	keys := generate(t, "LFU_SCAN", Params{MaxKey: 10000}, 5, 600+4*250)
+	freq := sorted(members(keys[:600]))
+	if len(freq) != 200 {
+		t.Fatalf("the warm-up covered %d keys, want the 200 frequent ones", len(freq))
+	}
+	// cycle the frequent set three times, then alternate a scan
+	// of every key with a phase of the frequent set
+	want := repeated(freq, 600)
+	for key := 0; key < 250; key++ {
+		want = append(want, key)
+	}
+	want = append(want, freq[:200]...)
+	want = append(want, freq[:50]...)
+	for key := 250; key < 500; key++ {
+		want = append(want, key)
+	}
+	want = append(want, freq[50:200]...)
+	want = append(want, freq[:100]...)
+	if !reflect.DeepEqual(keys, want[:len(keys)]) {
+		t.Errorf("LFU_SCAN doesn't follow generate_lfu_scan_keys")
+	}
+}
+
+func TestCostScan(t *testing.T) {
+	keys := generate(t, "LCR_SCAN", Params{MaxKey: 10000}, 5, 1500+4*250)
+	freq := sorted(members(keys[:750]))
+	cost := sorted(members(keys[750:1500]))
+	if len(freq) != 250 || len(cost) != 250 {
+		t.Fatalf("the warm-up covered %d frequent and %d cost keys, want 250 each", len(freq), len(cost))
+	}
+	// each set cycled three times, then scan, frequent and cost
+	// phases of 250 in turn, the scan skipping both sets
+	want := append(repeated(freq, 750), repeated(cost, 750)...)
+	for key := 0; len(want) < 1750; key++ {
+		if !members(freq)[key] && !members(cost)[key] {
+			want = append(want, key)
+		}
+	}
+	want = append(want, freq...)
+	want = append(want, cost...)
+	scanned := want[1500:1750]
+	for key := scanned[len(scanned)-1] + 1; len(want) < 2500; key++ {
+		if !members(freq)[key] && !members(cost)[key] {
+			want = append(want, key)
+		}
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("LCR_SCAN doesn't follow generate_lcr_scan_keys")
+	}
+	for _, key := range freq {
+		if key >= 1000 {
+			t.Fatalf("frequent key %d, they come from the first 1000", key)
+		}
+	}
+	for _, key := range cost {
+		if key < 9000 {
+			t.Fatalf("cost key %d, they come from the top tenth", key)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		params Params
+		err    string
+	}{
+		{"LRU", Params{}, "MaxKey must be positive"},
+		{"MRU", Params{MaxKey: 10}, "Unknown generator: MRU"},
+		{"LCR", Params{MaxKey: 1000}, "Can't pick 250 distinct keys from a range of 100"},
+		{"LFU", Params{MaxKey: 100}, "Can't pick 200 distinct keys from a range of 100"},
+		{"LFU", Params{MaxKey: 200}, "No keys left outside the frequent and cost sets"},
+	}
+	for _, c := range cases {
+		_, err := New(c.name, c.params, rand.New(rand.NewSource(1)))
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s with %+v: got error %v, want %q", c.name, c.params, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package tracegen
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+)
+
+/*Workload describes a trace as a tree of phases in JSON, so
+shifts in the traffic (a scan, then a hot set, then the costly
+keys...) can be set up without writing a new generator:
+
+	{
+	  "seed": 1,
+	  "max_key": 10000,
+	  "repeat": 4,
+	  "phases": [
+	    {"name": "scan", "generator": "UNIFORM", "key_range": [1000, 9000], "length": 2000},
+	    {"name": "mixed", "mode": "mixture", "length": 5000, "phases": [
+	      {"generator": "ZIPF", "alpha": 1.1, "key_range": [0, 1000], "weight": 3},
+	      {"generator": "UNIFORM", "key_range": [9000, 10000], "weight": 1}
+	    ]}
+	  ]
+	}
+
+The top level is itself a phase, with the seed and key space added*/
+type Workload struct {
+	Seed   int64 `json:"seed"`
+	MaxKey int   `json:"max_key"`
+	Phase
+}
+
+/*Phase is either a leaf, drawing keys from one generator over
+its key range, or a composite of child phases.  A "sequence"
+(the default) runs each child for its length in turn, Repeat
+times over; a "mixture" picks a child by weight for every
+request.  Generators keep their state across repeats, so a
+frequent set that comes back is the same set.  Key ranges are
+[from, to) in the workload's key space, and children without
+one inherit their parent's*/
+type Phase struct {
+	Name      string  `json:"name"`
+	Generator string  `json:"generator"`
+	KeyRange  []int   `json:"key_range"`
+	Length    int     `json:"length"`
+	Weight    float64 `json:"weight"`
+	Mode      string  `json:"mode"`
+	Repeat    int     `json:"repeat"`
+	Phases    []Phase `json:"phases"`
+	Params
+}
+
+/*ReadWorkload loads a workload spec from a JSON file*/
+func ReadWorkload(filename string) (*Workload, error) {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	workload := &Workload{}
+	err = json.Unmarshal(body, workload)
+	if err != nil {
+		return nil, err
+	}
+	if workload.MaxKey <= 0 {
+		return nil, errors.New("Workload needs a positive max_key")
+	}
+	return workload, nil
+}
+
+/*Length is how many requests one pass over the workload makes,
+or 0 when that isn't fixed (a mixture without a length)*/
+func (w *Workload) Length() int {
+	return w.Phase.length()
+}
+
+/*Compile turns the workload into a generator.  onPhase, if given,
+is called with the name of each named phase of a sequence as the
+trace enters it; nested names are joined with a slash*/
+func (w *Workload) Compile(rng *rand.Rand, onPhase func(string)) (Generator, error) {
+	return w.Phase.compile(rng, "", []int{0, w.MaxKey}, w.MaxKey, onPhase)
+}
+
+func (p *Phase) length() int {
+	if p.Length > 0 || len(p.Phases) == 0 || p.Mode == "mixture" {
+		return p.Length
+	}
+	total := 0
+	for i := range p.Phases {
+		total += p.Phases[i].length()
+	}
+	repeat := p.Repeat
+	if repeat <= 0 {
+		repeat = 1
+	}
+	return total * repeat
+}
+
+func (p *Phase) describe(path string) string {
+	if path == "" {
+		return "workload"
+	}
+	return "phase " + path
+}
+
+func (p *Phase) compile(rng *rand.Rand, path string, parentRange []int, maxKey int, onPhase func(string)) (Generator, error) {
+	keyRange := parentRange
+	if len(p.KeyRange) > 0 {
+		keyRange = p.KeyRange
+	}
+	if len(keyRange) != 2 || keyRange[0] < 0 || keyRange[1] > maxKey || keyRange[0] >= keyRange[1] {
+		return nil, errors.New("Bad key_range for " + p.describe(path) + ", it needs to be [from, to) within 0 and max_key")
+	}
+	if len(p.Phases) == 0 {
+		if p.Generator == "" {
+			return nil, errors.New("No generator or phases for " + p.describe(path))
+		}
+		params := p.Params
+		params.MaxKey = keyRange[1] - keyRange[0]
+		generator, err := New(p.Generator, params, rng)
+		if err != nil {
+			return nil, errors.New("Building " + p.describe(path) + ": " + err.Error())
+		}
+		offset := keyRange[0]
+		return generatorFunc(func() int { return offset + generator.Next() }), nil
+	}
+	if p.Generator != "" {
+		return nil, errors.New("A phase has either a generator or phases, not both: " + p.describe(path))
+	}
+	children := make([]Generator, len(p.Phases))
+	for i := range p.Phases {
+		child := &p.Phases[i]
+		childPath := child.Name
+		if childPath == "" {
+			childPath = strconv.Itoa(i)
+		}
+		if path != "" {
+			childPath = path + "/" + childPath
+		}
+		generator, err := child.compile(rng, childPath, keyRange, maxKey, onPhase)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = generator
+	}
+	if p.Mode == "mixture" {
+		return newMixture(rng, p, children, path)
+	} else if p.Mode == "" || p.Mode == "sequence" {
+		return newSequence(p, children, path, onPhase)
+	}
+	return nil, errors.New("Unknown mode " + p.Mode + " for " + p.describe(path) + ", use sequence or mixture")
+}
+
+/*sequence runs each child for its length in turn, going back
+to the first after the last*/
+type sequence struct {
+	children []Generator
+	lengths  []int
+	names    []string
+	onPhase  func(string)
+	index    int
+	served   int
+	started  bool
+}
+
+func newSequence(p *Phase, children []Generator, path string, onPhase func(string)) (*sequence, error) {
+	seq := &sequence{children: children, onPhase: onPhase}
+	for i := range p.Phases {
+		child := &p.Phases[i]
+		if child.length() <= 0 {
+			return nil, errors.New("Every phase of a sequence needs a length: " + p.describe(path))
+		}
+		seq.lengths = append(seq.lengths, child.length())
+		name := ""
+		if child.Name != "" {
+			name = child.Name
+			if path != "" {
+				name = path + "/" + name
+			}
+		}
+		seq.names = append(seq.names, name)
+	}
+	return seq, nil
+}
+
+func (seq *sequence) Next() int {
+	if !seq.started || seq.served >= seq.lengths[seq.index] {
+		if seq.started {
+			seq.index = (seq.index + 1) % len(seq.children)
+		}
+		seq.started = true
+		seq.served = 0
+		if seq.onPhase != nil && seq.names[seq.index] != "" {
+			seq.onPhase(seq.names[seq.index])
+		}
+	}
+	seq.served++
+	return seq.children[seq.index].Next()
+}
+
+/*newMixture picks a child by weight for every request*/
+func newMixture(rng *rand.Rand, p *Phase, children []Generator, path string) (Generator, error) {
+	cumulative := make([]float64, len(p.Phases))
+	total := 0.0
+	for i := range p.Phases {
+		if p.Phases[i].Weight <= 0 {
+			return nil, errors.New("Every phase of a mixture needs a positive weight: " + p.describe(path))
+		}
+		total += p.Phases[i].Weight
+		cumulative[i] = total
+	}
+	return generatorFunc(func() int {
+		roll := rng.Float64() * total
+		for i, bound := range cumulative {
+			if roll < bound {
+				return children[i].Next()
+			}
+		}
+		return children[len(children)-1].Next()
+	}), nil
+}
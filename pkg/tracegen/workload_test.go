@@ -0,0 +1,208 @@
+package tracegen
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func parseWorkload(t *testing.T, spec string) *Workload {
+	workload := &Workload{}
+	err := json.Unmarshal([]byte(spec), workload)
+	if err != nil {
+		t.Fatalf("%s: %v", spec, err)
+	}
+	return workload
+}
+
+/*entered is a phase name and the request it started at*/
+type entered struct {
+	request int
+	name    string
+}
+
+/*run draws count keys from the compiled workload, noting where
+each phase starts*/
+func run(t *testing.T, workload *Workload, count int) ([]int, []entered) {
+	phases := []entered{}
+	keys := []int{}
+	generator, err := workload.Compile(rand.New(rand.NewSource(1)), func(name string) {
+		phases = append(phases, entered{len(keys), name})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		keys = append(keys, generator.Next())
+	}
+	return keys, phases
+}
+
+func TestCheckedInWorkload(t *testing.T) {
+	workload, err := ReadWorkload("../../data/workloads/lcr_shift.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workload.Seed != 1 || workload.MaxKey != 10000 || workload.Length() != 20000+4*12000 {
+		t.Fatalf("read seed %d, max_key %d and length %d", workload.Seed, workload.MaxKey, workload.Length())
+	}
+	keys, phases := run(t, workload, workload.Length()+10)
+	want := []entered{{0, "warmup"}, {20000, "shifts"}}
+	for start := 20000; start < workload.Length(); start += 12000 {
+		want = append(want, entered{start, "shifts/costly"}, entered{start + 5000, "shifts/scan"}, entered{start + 7000, "shifts/frequent"})
+	}
+	// and back around to the start
+	want = append(want, entered{workload.Length(), "warmup"})
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("entered phases %v, want %v", phases, want)
+	}
+	ranges := []struct {
+		from, to  int
+		low, high int
+	}{
+		{0, 20000, 0, 1000},
+		{20000, 25000, 9000, 9250},
+		{25000, 27000, 1000, 9000},
+		{68000, 68010, 0, 1000},
+	}
+	for _, r := range ranges {
+		for i, key := range keys[r.from:r.to] {
+			if key < r.low || key >= r.high {
+				t.Fatalf("request %d got key %d, outside [%d, %d)", r.from+i, key, r.low, r.high)
+			}
+		}
+	}
+	mixed := share(keys[27000:32000], 0, 1000)
+	if mixed < 0.72 || mixed > 0.78 {
+		t.Errorf("the 3:1 mixture sent %.3f of requests to the first child", mixed)
+	}
+	again, _ := run(t, workload, len(keys))
+	if !reflect.DeepEqual(keys, again) {
+		t.Error("the same workload and seed gave two different traces")
+	}
+}
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		spec string
+		want int
+	}{
+		{`{"generator": "UNIFORM", "length": 7}`, 7},
+		{`{"phases": [{"generator": "UNIFORM", "length": 3}, {"generator": "UNIFORM", "length": 4}]}`, 7},
+		{`{"repeat": 3, "phases": [{"generator": "UNIFORM", "length": 3}, {"repeat": 2, "phases": [{"generator": "UNIFORM", "length": 1}]}]}`, 15},
+		{`{"length": 100, "phases": [{"generator": "UNIFORM", "length": 3}]}`, 100},
+		{`{"mode": "mixture", "phases": [{"generator": "UNIFORM", "weight": 1}]}`, 0},
+		{`{"generator": "UNIFORM"}`, 0},
+	}
+	for _, c := range cases {
+		if got := parseWorkload(t, c.spec).Length(); got != c.want {
+			t.Errorf("%s: length %d, want %d", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	workload := parseWorkload(t, `{"max_key": 30, "phases": [
+		{"name": "a", "generator": "UNIFORM", "key_range": [0, 10], "length": 2},
+		{"generator": "UNIFORM", "key_range": [10, 20], "length": 3},
+		{"name": "c", "phases": [
+			{"name": "d", "generator": "UNIFORM", "key_range": [20, 25], "length": 1},
+			{"name": "e", "generator": "UNIFORM", "key_range": [25, 30], "length": 1}
+		]}
+	]}`)
+	keys, phases := run(t, workload, 14)
+	bands := []int{0, 0, 1, 1, 1, 2, 2, 0, 0, 1, 1, 1, 2, 2}
+	for i, key := range keys {
+		if key/10 != bands[i] {
+			t.Errorf("request %d got key %d, want one from [%d, %d)", i, key, bands[i]*10, bands[i]*10+10)
+		}
+	}
+	if keys[5] >= 25 || keys[6] < 25 || keys[12] >= 25 || keys[13] < 25 {
+		t.Errorf("c didn't alternate d and e: %v", keys)
+	}
+	// the unnamed phase isn't reported
+	want := []entered{{0, "a"}, {5, "c"}, {5, "c/d"}, {6, "c/e"}, {7, "a"}, {12, "c"}, {12, "c/d"}, {13, "c/e"}}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("entered phases %v, want %v", phases, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		spec string
+		err  string
+	}{
+		{`{"max_key": 10}`, "No generator or phases for workload"},
+		{`{"max_key": 10, "generator": "NOPE"}`, "Building workload: Unknown generator: NOPE"},
+		{`{"max_key": 10, "key_range": [0, 11], "generator": "UNIFORM"}`, "Bad key_range for workload, it needs to be [from, to) within 0 and max_key"},
+		{`{"max_key": 10, "phases": [{"name": "x", "key_range": [5, 5], "generator": "UNIFORM", "length": 1}]}`,
+			"Bad key_range for phase x, it needs to be [from, to) within 0 and max_key"},
+		{`{"max_key": 10, "phases": [{"key_range": [1], "generator": "UNIFORM", "length": 1}]}`,
+			"Bad key_range for phase 0, it needs to be [from, to) within 0 and max_key"},
+		{`{"max_key": 10, "generator": "UNIFORM", "phases": [{"generator": "UNIFORM", "length": 1}]}`,
+			"A phase has either a generator or phases, not both: workload"},
+		{`{"max_key": 10, "mode": "shuffle", "phases": [{"generator": "UNIFORM", "length": 1}]}`,
+			"Unknown mode shuffle for workload, use sequence or mixture"},
+		{`{"max_key": 10, "phases": [{"name": "x", "phases": [{"generator": "UNIFORM", "length": 1}, {"generator": "UNIFORM"}]}]}`,
+			"Every phase of a sequence needs a length: phase x"},
+		{`{"max_key": 10, "mode": "mixture", "phases": [{"generator": "UNIFORM", "weight": 1}, {"generator": "UNIFORM"}]}`,
+			"Every phase of a mixture needs a positive weight: workload"},
+		{`{"max_key": 10, "phases": [{"name": "hot", "generator": "HOTSPOT", "hot_prob": 2, "length": 1}]}`,
+			"Building phase hot: Hot fraction and probability must be between 0 and 1"},
+	}
+	for _, c := range cases {
+		_, err := parseWorkload(t, c.spec).Compile(rand.New(rand.NewSource(1)), nil)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.spec, err, c.err)
+		}
+	}
+}
+
+func TestReadWorkloadErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		body string
+		err  string
+	}{
+		{`{"phases": []}`, "Workload needs a positive max_key"},
+		{`{"max_key": -4}`, "Workload needs a positive max_key"},
+		{`{"max_key": "many"}`, "json: cannot unmarshal string into Go struct field Workload.max_key of type int"},
+	}
+	for i, c := range cases {
+		filename := filepath.Join(dir, strconv.Itoa(i)+".json")
+		err := ioutil.WriteFile(filename, []byte(c.body), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ReadWorkload(filename)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.body, err, c.err)
+		}
+	}
+	_, err := ReadWorkload(filepath.Join(dir, "missing.json"))
+	if err == nil {
+		t.Error("reading a missing workload should fail")
+	}
+}
+
+func TestMaxKeyOverride(t *testing.T) {
+	// what -max_key does to a spec: ranges are checked against it
+	workload := parseWorkload(t, `{"max_key": 100, "generator": "UNIFORM"}`)
+	workload.MaxKey = 10
+	keys, _ := run(t, workload, 1000)
+	for _, key := range keys {
+		if key >= 10 {
+			t.Fatalf("got key %d with max_key overridden to 10", key)
+		}
+	}
+	workload = parseWorkload(t, `{"max_key": 100, "key_range": [50, 100], "generator": "UNIFORM"}`)
+	workload.MaxKey = 10
+	_, err := workload.Compile(rand.New(rand.NewSource(1)), nil)
+	if err == nil {
+		t.Error("a key_range past the overridden max_key should fail")
+	}
+}
@@ -0,0 +1,144 @@
+package traceinfo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+/*CostSummary is the spread of a set of costs*/
+type CostSummary struct {
+	Count int
+	Mean  float64
+	Min   int
+	P50   int
+	P90   int
+	P99   int
+	Max   int
+}
+
+/*Costs joins the requested keys against the dataset.  Requests
+weights each key by how often it was requested, which is what a
+cache sees; Keys counts each distinct key once.  Total is what the
+trace would cost with no cache at all, and Correlation is the
+spearman rank correlation between a key's popularity and its cost*/
+type Costs struct {
+	Requests    CostSummary
+	Keys        CostSummary
+	Total       int
+	Missing     int
+	Correlation float64
+}
+
+type keyCost struct {
+	count int
+	cost  int
+}
+
+/*JoinCosts looks up the cost of every key the trace requested.
+Keys missing from the dataset are counted but left out of the
+summaries*/
+func (a *Analyzer) JoinCosts(records map[string]dataset.Record) Costs {
+	joined := []keyCost{}
+	costs := Costs{}
+	for key, count := range a.counts {
+		record, ok := records[key]
+		if !ok {
+			costs.Missing++
+			continue
+		}
+		joined = append(joined, keyCost{count: count, cost: record.Cost})
+		costs.Total += count * record.Cost
+	}
+	sort.Slice(joined, func(i, j int) bool { return joined[i].cost < joined[j].cost })
+	costs.Requests = summarize(joined, func(k keyCost) int { return k.count })
+	costs.Keys = summarize(joined, func(k keyCost) int { return 1 })
+	costs.Correlation = spearman(joined)
+	return costs
+}
+
+/*summarize takes keys sorted by cost, weighting each one*/
+func summarize(joined []keyCost, weight func(keyCost) int) CostSummary {
+	summary := CostSummary{}
+	if len(joined) == 0 {
+		return summary
+	}
+	total := 0
+	sum := 0.0
+	for _, k := range joined {
+		total += weight(k)
+		sum += float64(weight(k) * k.cost)
+	}
+	summary.Count = total
+	summary.Mean = sum / float64(total)
+	summary.Min = joined[0].cost
+	summary.Max = joined[len(joined)-1].cost
+	percentile := func(p float64) int {
+		target := int(math.Ceil(p * float64(total)))
+		seen := 0
+		for _, k := range joined {
+			seen += weight(k)
+			if seen >= target {
+				return k.cost
+			}
+		}
+		return summary.Max
+	}
+	summary.P50 = percentile(0.5)
+	summary.P90 = percentile(0.9)
+	summary.P99 = percentile(0.99)
+	return summary
+}
+
+func spearman(joined []keyCost) float64 {
+	counts := make([]float64, len(joined))
+	costs := make([]float64, len(joined))
+	for i, k := range joined {
+		counts[i] = float64(k.count)
+		costs[i] = float64(k.cost)
+	}
+	return pearson(ranks(counts), ranks(costs))
+}
+
+/*ranks gives tied values the average of the ranks they span*/
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	ranked := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start
+		for end+1 < len(order) && values[order[end+1]] == values[order[start]] {
+			end++
+		}
+		for i := start; i <= end; i++ {
+			ranked[order[i]] = float64(start+end) / 2
+		}
+		start = end + 1
+	}
+	return ranked
+}
+
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXX, sumYY, sumXY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXX += xs[i] * xs[i]
+		sumYY += ys[i] * ys[i]
+		sumXY += xs[i] * ys[i]
+	}
+	varX := n*sumXX - sumX*sumX
+	varY := n*sumYY - sumY*sumY
+	if varX <= 0 || varY <= 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / math.Sqrt(varX*varY)
+}
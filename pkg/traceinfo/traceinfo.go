@@ -0,0 +1,242 @@
+package traceinfo
+
+import (
+	"math"
+	"sort"
+)
+
+/*Analyzer takes a trace one key at a time and keeps what it needs
+for every statistic in one pass: request counts per key, the last
+time each key was seen, and a fenwick tree marking the latest
+access of each key so stack distances come out in O(log n)*/
+type Analyzer struct {
+	window    int
+	requests  int
+	counts    map[string]int
+	last      map[string]int
+	marks     fenwick
+	reuse     []int
+	stack     []int
+	windowSet map[string]bool
+	windows   []int
+}
+
+/*NewAnalyzer counts working sets over windows of the given number
+of requests*/
+func NewAnalyzer(window int) *Analyzer {
+	return &Analyzer{
+		window:    window,
+		counts:    map[string]int{},
+		last:      map[string]int{},
+		windowSet: map[string]bool{},
+	}
+}
+
+/*Add takes the next request of the trace*/
+func (a *Analyzer) Add(key string) {
+	a.requests++
+	t := a.requests
+	a.counts[key]++
+	if p, seen := a.last[key]; seen {
+		// distinct keys touched since p are the marks strictly between p and t
+		distance := int(a.marks.prefix(t-1) - a.marks.prefix(p))
+		a.stack = bump(a.stack, distance)
+		a.reuse = bump(a.reuse, log2(t-p))
+		a.marks.add(p, -1)
+	}
+	a.marks.push(1)
+	a.last[key] = t
+	if a.window > 0 {
+		a.windowSet[key] = true
+		if t%a.window == 0 {
+			a.windows = append(a.windows, len(a.windowSet))
+			a.windowSet = map[string]bool{}
+		}
+	}
+}
+
+/*Requests is how many keys the trace had*/
+func (a *Analyzer) Requests() int {
+	return a.requests
+}
+
+/*Unique is how many distinct keys the trace had*/
+func (a *Analyzer) Unique() int {
+	return len(a.counts)
+}
+
+/*Counts is the number of requests for each key*/
+func (a *Analyzer) Counts() map[string]int {
+	return a.counts
+}
+
+/*ReuseBucket is the number of re-requests whose reuse time, the
+requests since the key's last request, falls within [Low, High]*/
+type ReuseBucket struct {
+	Low   int
+	High  int
+	Count int
+}
+
+/*ReuseHistogram buckets reuse times by powers of two.  First
+requests have no reuse time and aren't counted*/
+func (a *Analyzer) ReuseHistogram() []ReuseBucket {
+	buckets := []ReuseBucket{}
+	for i, count := range a.reuse {
+		buckets = append(buckets, ReuseBucket{Low: 1 << uint(i), High: 1<<uint(i+1) - 1, Count: count})
+	}
+	return buckets
+}
+
+/*MissRatio is what an LRU cache of Size entries would miss on this
+trace, counting the first request for every key as a miss*/
+type MissRatio struct {
+	Size      int
+	MissRatio float64
+}
+
+/*MissRatioCurve works out the LRU miss ratio at each size from the
+stack distances: a request hits in a cache of size C exactly when
+fewer than C other keys were requested since its last request*/
+func (a *Analyzer) MissRatioCurve(sizes []int) []MissRatio {
+	cumulative := make([]int, len(a.stack)+1)
+	for i, count := range a.stack {
+		cumulative[i+1] = cumulative[i] + count
+	}
+	curve := []MissRatio{}
+	for _, size := range sizes {
+		hits := cumulative[len(cumulative)-1]
+		if size < len(cumulative) {
+			hits = cumulative[size]
+		}
+		curve = append(curve, MissRatio{Size: size, MissRatio: 1 - ratio(hits, a.requests)})
+	}
+	return curve
+}
+
+/*DefaultSizes picks cache sizes for a miss ratio curve: powers of
+two up to the number of distinct keys, and that number itself*/
+func (a *Analyzer) DefaultSizes() []int {
+	sizes := []int{}
+	for size := 1; size < a.Unique(); size *= 2 {
+		sizes = append(sizes, size)
+	}
+	return append(sizes, a.Unique())
+}
+
+/*WorkingSets is the number of distinct keys in each full window,
+plus the last partial one if there is one*/
+func (a *Analyzer) WorkingSets() []int {
+	if len(a.windowSet) > 0 {
+		return append(append([]int{}, a.windows...), len(a.windowSet))
+	}
+	return a.windows
+}
+
+/*Window is the number of requests per working set window*/
+func (a *Analyzer) Window() int {
+	return a.window
+}
+
+/*Skew is a least squares fit of log(requests) against log(rank)
+over the keys ranked by popularity, so Alpha is the zipf exponent
+the trace looks most like and R2 how well it fits.  TopShares are
+the fractions of requests that went to the most popular keys, one
+for each of TopFractions*/
+type Skew struct {
+	Alpha     float64
+	R2        float64
+	TopShares []float64
+}
+
+/*TopFractions are the shares of the keys FitSkew reports the
+requests going to*/
+var TopFractions = []float64{0.01, 0.1, 0.5}
+
+/*FitSkew fits a zipf distribution to the key popularity*/
+func (a *Analyzer) FitSkew() Skew {
+	counts := []int{}
+	for _, count := range a.counts {
+		counts = append(counts, count)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	var n, sumX, sumY, sumXX, sumXY, sumYY float64
+	for i, count := range counts {
+		x := math.Log(float64(i + 1))
+		y := math.Log(float64(count))
+		n++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+		sumYY += y * y
+	}
+	skew := Skew{}
+	varX := n*sumXX - sumX*sumX
+	varY := n*sumYY - sumY*sumY
+	if varX > 0 {
+		covariance := n*sumXY - sumX*sumY
+		skew.Alpha = -covariance / varX
+		if varY > 0 {
+			skew.R2 = covariance * covariance / (varX * varY)
+		}
+	}
+	for _, fraction := range TopFractions {
+		top := int(math.Ceil(fraction * float64(len(counts))))
+		requests := 0
+		for _, count := range counts[:top] {
+			requests += count
+		}
+		skew.TopShares = append(skew.TopShares, ratio(requests, a.requests))
+	}
+	return skew
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+func bump(histogram []int, bucket int) []int {
+	for len(histogram) <= bucket {
+		histogram = append(histogram, 0)
+	}
+	histogram[bucket]++
+	return histogram
+}
+
+func log2(n int) int {
+	bucket := 0
+	for n > 1 {
+		n >>= 1
+		bucket++
+	}
+	return bucket
+}
+
+/*fenwick is a binary indexed tree over request positions 1..n
+that grows one position at a time as the trace streams in*/
+type fenwick []int32
+
+func (f *fenwick) push(value int32) {
+	i := len(*f) + 1
+	// node i covers (i-lowbit(i), i], so fold in what's already there
+	node := value + f.prefix(i-1) - f.prefix(i-(i&-i))
+	*f = append(*f, node)
+}
+
+func (f fenwick) add(i int, delta int32) {
+	for ; i <= len(f); i += i & -i {
+		f[i-1] += delta
+	}
+}
+
+func (f fenwick) prefix(i int) int32 {
+	var sum int32
+	for ; i > 0; i -= i & -i {
+		sum += f[i-1]
+	}
+	return sum
+}
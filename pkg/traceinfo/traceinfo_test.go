@@ -0,0 +1,147 @@
+package traceinfo
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/dataset"
+)
+
+func analyze(window int, keys ...string) *Analyzer {
+	a := NewAnalyzer(window)
+	for _, key := range keys {
+		a.Add(key)
+	}
+	return a
+}
+
+/*lruMisses simulates an LRU cache of the given size the slow way*/
+func lruMisses(keys []string, size int) int {
+	misses := 0
+	stack := []string{}
+	for _, key := range keys {
+		found := -1
+		for i, k := range stack {
+			if k == key {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			misses++
+			if len(stack) == size {
+				stack = stack[:size-1]
+			}
+		} else {
+			stack = append(stack[:found], stack[found+1:]...)
+		}
+		stack = append([]string{key}, stack...)
+	}
+	return misses
+}
+
+func TestMissRatioCurveMatchesLRU(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	keys := []string{}
+	for i := 0; i < 3000; i++ {
+		// skewed enough that every size sees some hits
+		keys = append(keys, strconv.Itoa(int(rng.ExpFloat64()*20)))
+	}
+	a := analyze(0, keys...)
+	sizes := append(a.DefaultSizes(), a.Unique()*2)
+	for _, point := range a.MissRatioCurve(sizes) {
+		want := float64(lruMisses(keys, point.Size)) / float64(len(keys))
+		if math.Abs(point.MissRatio-want) > 1e-9 {
+			t.Errorf("size %d: miss ratio %v, LRU missed %v", point.Size, point.MissRatio, want)
+		}
+	}
+}
+
+func TestStackDistances(t *testing.T) {
+	a := analyze(0, "a", "b", "c", "a", "a", "b")
+	// a sees b and c in between, then nothing; b sees c and a
+	if !reflect.DeepEqual(a.stack, []int{1, 0, 2}) {
+		t.Errorf("stack distances %v", a.stack)
+	}
+	want := []MissRatio{{Size: 1, MissRatio: 5.0 / 6}, {Size: 2, MissRatio: 5.0 / 6}, {Size: 3, MissRatio: 0.5}}
+	if got := a.MissRatioCurve(a.DefaultSizes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got curve %v, want %v", got, want)
+	}
+}
+
+func TestReuseHistogram(t *testing.T) {
+	a := analyze(0, "a", "a", "b", "a", "c", "d", "e", "f", "a")
+	// reuse times of 1, 2 and 5
+	want := []ReuseBucket{{1, 1, 1}, {2, 3, 1}, {4, 7, 1}}
+	if got := a.ReuseHistogram(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(analyze(0, "a", "b").ReuseHistogram()) != 0 {
+		t.Error("first requests shouldn't have a reuse time")
+	}
+}
+
+func TestWorkingSets(t *testing.T) {
+	cases := []struct {
+		window int
+		keys   []string
+		want   []int
+	}{
+		{3, []string{"a", "a", "b", "c", "d", "c", "a"}, []int{2, 2, 1}},
+		{2, []string{"a", "b", "a", "a"}, []int{2, 1}},
+		{0, []string{"a", "b"}, nil},
+	}
+	for _, c := range cases {
+		if got := analyze(c.window, c.keys...).WorkingSets(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("window %d over %v: got %v, want %v", c.window, c.keys, got, c.want)
+		}
+	}
+}
+
+func TestFitSkew(t *testing.T) {
+	a := NewAnalyzer(0)
+	// 60/rank requests for ranks 1 to 6 is exactly zipf with alpha 1
+	for rank := 1; rank <= 6; rank++ {
+		for i := 0; i < 60/rank; i++ {
+			a.Add(strconv.Itoa(rank))
+		}
+	}
+	skew := a.FitSkew()
+	if math.Abs(skew.Alpha-1) > 1e-9 || math.Abs(skew.R2-1) > 1e-9 {
+		t.Errorf("got alpha %v with r2 %v, want 1 and 1", skew.Alpha, skew.R2)
+	}
+	total := 60.0 + 30 + 20 + 15 + 12 + 10
+	want := []float64{60 / total, 60 / total, (60 + 30 + 20) / total}
+	if !reflect.DeepEqual(skew.TopShares, want) {
+		t.Errorf("got top shares %v, want %v", skew.TopShares, want)
+	}
+	uniform := analyze(0, "a", "b", "c").FitSkew()
+	if uniform.Alpha != 0 || uniform.R2 != 0 {
+		t.Errorf("uniform popularity fit alpha %v with r2 %v", uniform.Alpha, uniform.R2)
+	}
+}
+
+func TestJoinCosts(t *testing.T) {
+	a := analyze(0, "a", "a", "a", "b", "c", "missing")
+	records := map[string]dataset.Record{
+		"a": {Key: "a", Cost: 10},
+		"b": {Key: "b", Cost: 20},
+		"c": {Key: "c", Cost: 30},
+	}
+	costs := a.JoinCosts(records)
+	if costs.Total != 80 || costs.Missing != 1 {
+		t.Errorf("got total %d and %d missing, want 80 and 1", costs.Total, costs.Missing)
+	}
+	if costs.Requests.Count != 5 || costs.Requests.Mean != 16 || costs.Requests.P50 != 10 {
+		t.Errorf("request weighted summary %+v", costs.Requests)
+	}
+	if costs.Keys.Count != 3 || costs.Keys.Mean != 20 || costs.Keys.Min != 10 || costs.Keys.Max != 30 {
+		t.Errorf("key summary %+v", costs.Keys)
+	}
+	if costs.Correlation >= 0 {
+		t.Errorf("the popular key is the cheapest, got correlation %v", costs.Correlation)
+	}
+}
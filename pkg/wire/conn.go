@@ -0,0 +1,128 @@
+package wire
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+/*Conn is a client connection speaking the binary protocol.
+Unlike the text protocol a connection carries any number of
+requests, one at a time.  It is not safe for concurrent use*/
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	timeout time.Duration
+}
+
+/*Dial connects to a server's binary listener.  A timeout of 0
+means requests wait as long as the server takes*/
+func Dial(network string, address string, timeout time.Duration) (*Conn, error) {
+	conn, err := net.DialTimeout(network, address, dialTimeout(timeout))
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn, timeout), nil
+}
+
+/*NewConn speaks the binary protocol over a connection
+that's already been dialed*/
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		writer:  bufio.NewWriter(conn),
+		timeout: timeout,
+	}
+}
+
+func dialTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return 30 * time.Second
+	}
+	return timeout
+}
+
+/*Do sends one request and waits for its response*/
+func (c *Conn) Do(req *Request) (*Response, error) {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	err := WriteRequest(c.writer, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ReadResponse(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	err = StatusError(resp.Status)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+/*Fetch asks for one key, returning its value, the cost paid
+for it (0 on a hit) and whether the cache served it*/
+func (c *Conn) Fetch(key string) (string, int, bool, error) {
+	resp, err := c.Do(&Request{Op: OpFetch, Keys: []string{key}})
+	if err != nil {
+		return "", 0, false, err
+	}
+	if len(resp.Results) != 1 {
+		return "", 0, false, StatusError(StatusServerError)
+	}
+	result := resp.Results[0]
+	return result.Value, int(result.Cost), result.Hit, StatusError(result.Status)
+}
+
+/*MFetch asks for several keys in one request.  The server
+consults the cache in key order, and results come back in
+the same order*/
+func (c *Conn) MFetch(keys []string) ([]Result, error) {
+	resp, err := c.Do(&Request{Op: OpMFetch, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(keys) {
+		return nil, StatusError(StatusServerError)
+	}
+	return resp.Results, nil
+}
+
+/*Delete drops a key from the cache*/
+func (c *Conn) Delete(key string) error {
+	resp, err := c.Do(&Request{Op: OpDelete, Keys: []string{key}})
+	if err != nil {
+		return err
+	}
+	if len(resp.Results) != 1 {
+		return StatusError(StatusServerError)
+	}
+	return StatusError(resp.Results[0].Status)
+}
+
+/*Stats returns the server's counters as KEY:value lines*/
+func (c *Conn) Stats() (string, error) {
+	resp, err := c.Do(&Request{Op: OpStats})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Results) != 1 {
+		return "", StatusError(StatusServerError)
+	}
+	return resp.Results[0].Value, nil
+}
+
+/*SetDeadline bounds requests on the connection by an absolute
+time, on top of any timeout; the zero time clears it*/
+func (c *Conn) SetDeadline(deadline time.Time) error {
+	return c.conn.SetDeadline(deadline)
+}
+
+/*Close hangs up*/
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,296 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+/*Version is the protocol version written in every frame.
+Frames carrying any other version are refused*/
+const Version = 2
+
+/*MaxFrameSize bounds how much a peer will buffer for one frame:
+room for an mfetch of a few hundred keys, or a response carrying
+a dozen values at memcached's 1MB item limit*/
+const MaxFrameSize = 16 * 1024 * 1024
+
+/*ErrFrameTooLarge is returned by the writers for a frame the other
+side would refuse to read, before any of it is written*/
+var ErrFrameTooLarge = errors.New("Frame is too large")
+
+/*Opcodes say what a request is asking for*/
+const (
+	OpFetch  byte = 1
+	OpMFetch byte = 2
+	OpDelete byte = 3
+	OpStats  byte = 4
+)
+
+/*Status codes, used both for a whole response and
+for each result inside it*/
+const (
+	StatusOK          byte = 0
+	StatusNotFound    byte = 1
+	StatusBadRequest  byte = 2
+	StatusServerError byte = 3
+)
+
+const flagHit byte = 1
+
+/*Request is one framed command.  Fetch and delete carry one key,
+mfetch carries any number and stats carries none.
+
+	uint32 frame length (of everything after it)
+	uint8  version
+	uint8  opcode
+	uint16 key count
+	  uint16 key length
+	  bytes  key
+*/
+type Request struct {
+	Op   byte
+	Keys []string
+}
+
+/*Result is the answer for one key: the value, what it cost
+(0 on a hit, like the text protocol), what a hit saved and
+whether the cache served it.  Stats puts its summary in the value.
+
+	uint8  status
+	uint8  flags (bit 0 set on a hit)
+	int64  cost
+	int64  saved
+	uint32 value length
+	bytes  value
+*/
+type Result struct {
+	Status byte
+	Hit    bool
+	Cost   int64
+	Saved  int64
+	Value  string
+}
+
+/*Response is one framed answer, with a result per requested key.
+
+uint32 frame length (of everything after it)
+uint8  version
+uint8  status
+uint16 result count
+  result...
+*/
+type Response struct {
+	Status  byte
+	Results []Result
+}
+
+/*StatusError describes a status code as an error, nil for StatusOK*/
+func StatusError(status byte) error {
+	if status == StatusOK {
+		return nil
+	} else if status == StatusNotFound {
+		return errors.New("Key not found")
+	} else if status == StatusBadRequest {
+		return errors.New("Bad request")
+	} else if status == StatusServerError {
+		return errors.New("Server error")
+	}
+	return errors.New("Unknown status " + strconv.Itoa(int(status)))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func writeFrame(w *bufio.Writer, body []byte) error {
+	if len(body) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(body)))
+	_, err := w.Write(length[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var length [4]byte
+	_, err := io.ReadFull(r, length[:])
+	if err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > MaxFrameSize {
+		return nil, errors.New("Frame of " + strconv.FormatUint(uint64(size), 10) + " bytes is too large")
+	}
+	// read as the bytes arrive, so a header alone can't make us
+	// reserve the whole frame
+	var frame bytes.Buffer
+	_, err = io.CopyN(&frame, r, int64(size))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	body := frame.Bytes()
+	if len(body) < 2 || body[0] != Version {
+		return nil, errors.New("Unsupported protocol version")
+	}
+	return body, nil
+}
+
+/*frameReader walks a frame body, remembering the first
+time it ran out of bytes*/
+type frameReader struct {
+	body []byte
+	err  error
+}
+
+func (fr *frameReader) take(n int) []byte {
+	if fr.err != nil {
+		return nil
+	}
+	if len(fr.body) < n {
+		fr.err = errors.New("Truncated frame")
+		return nil
+	}
+	taken := fr.body[:n]
+	fr.body = fr.body[n:]
+	return taken
+}
+
+func (fr *frameReader) uint8() byte {
+	b := fr.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (fr *frameReader) uint16() int {
+	b := fr.take(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+func (fr *frameReader) uint32() int {
+	b := fr.take(4)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(b))
+}
+
+func (fr *frameReader) int64() int64 {
+	b := fr.take(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+/*WriteRequest frames a request onto the writer and flushes it*/
+func WriteRequest(w *bufio.Writer, req *Request) error {
+	if len(req.Keys) > 0xFFFF {
+		return errors.New("Too many keys for one request")
+	}
+	body := []byte{Version, req.Op}
+	body = appendUint16(body, uint16(len(req.Keys)))
+	for _, key := range req.Keys {
+		if len(key) > 0xFFFF {
+			return errors.New("Key is too long")
+		}
+		body = appendUint16(body, uint16(len(key)))
+		body = append(body, key...)
+	}
+	return writeFrame(w, body)
+}
+
+/*ReadRequest reads the next framed request*/
+func ReadRequest(r *bufio.Reader) (*Request, error) {
+	body, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	fr := &frameReader{body: body[1:]}
+	req := &Request{Op: fr.uint8()}
+	count := fr.uint16()
+	for i := 0; i < count && fr.err == nil; i++ {
+		req.Keys = append(req.Keys, string(fr.take(fr.uint16())))
+	}
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	return req, nil
+}
+
+/*WriteResponse frames a response onto the writer and flushes it*/
+func WriteResponse(w *bufio.Writer, resp *Response) error {
+	if len(resp.Results) > 0xFFFF {
+		return errors.New("Too many results for one response")
+	}
+	body := []byte{Version, resp.Status}
+	body = appendUint16(body, uint16(len(resp.Results)))
+	for _, result := range resp.Results {
+		flags := byte(0)
+		if result.Hit {
+			flags = flags | flagHit
+		}
+		body = append(body, result.Status, flags)
+		body = appendUint64(body, uint64(result.Cost))
+		body = appendUint64(body, uint64(result.Saved))
+		body = appendUint32(body, uint32(len(result.Value)))
+		body = append(body, result.Value...)
+	}
+	return writeFrame(w, body)
+}
+
+/*ReadResponse reads the next framed response*/
+func ReadResponse(r *bufio.Reader) (*Response, error) {
+	body, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	fr := &frameReader{body: body[1:]}
+	resp := &Response{Status: fr.uint8()}
+	count := fr.uint16()
+	for i := 0; i < count && fr.err == nil; i++ {
+		result := Result{Status: fr.uint8()}
+		result.Hit = fr.uint8()&flagHit != 0
+		result.Cost = fr.int64()
+		result.Saved = fr.int64()
+		result.Value = string(fr.take(fr.uint32()))
+		resp.Results = append(resp.Results, result)
+	}
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	return resp, nil
+}
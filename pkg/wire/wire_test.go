@@ -0,0 +1,140 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	requests := []*Request{
+		{Op: OpFetch, Keys: []string{"key1"}},
+		{Op: OpMFetch, Keys: []string{"key1", "", "a,b:c\nd"}},
+		{Op: OpStats},
+	}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, req := range requests {
+		err := WriteRequest(w, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, want := range requests {
+		got, err := ReadRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	_, err := ReadRequest(r)
+	if err != io.EOF {
+		t.Errorf("got %v after the last frame, want EOF", err)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	want := &Response{Status: StatusOK, Results: []Result{
+		{Status: StatusOK, Hit: true, Cost: 0, Saved: 1002, Value: "val1"},
+		{Status: StatusNotFound},
+		{Status: StatusOK, Cost: 1 << 40, Value: strings.Repeat("v", 70000)},
+	}}
+	var buf bytes.Buffer
+	err := WriteResponse(bufio.NewWriter(&buf), want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadResponse(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFramingErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame []byte
+		err   string
+	}{
+		{"too large", []byte{0x10, 0, 0, 0}, "Frame of 268435456 bytes is too large"},
+		{"bad version", []byte{0, 0, 0, 4, 1, OpFetch, 0, 0}, "Unsupported protocol version"},
+		{"no opcode", []byte{0, 0, 0, 1, Version}, "Unsupported protocol version"},
+		{"missing key", []byte{0, 0, 0, 4, Version, OpMFetch, 0, 2}, "Truncated frame"},
+		{"short key", []byte{0, 0, 0, 7, Version, OpFetch, 0, 1, 0, 9, 'k'}, "Truncated frame"},
+		{"short count", []byte{0, 0, 0, 3, Version, OpFetch, 0}, "Truncated frame"},
+		{"cut off length", []byte{0, 0}, io.ErrUnexpectedEOF.Error()},
+		{"cut off body", []byte{0, 0, 0, 8, Version, OpFetch}, io.ErrUnexpectedEOF.Error()},
+		{"just a header", []byte{0, 0, 0, 8}, io.ErrUnexpectedEOF.Error()},
+	}
+	for _, c := range cases {
+		_, err := ReadRequest(bufio.NewReader(bytes.NewReader(c.frame)))
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+		}
+	}
+	_, err := ReadResponse(bufio.NewReader(bytes.NewReader([]byte{0, 0, 0, 10, Version, StatusOK, 0, 1, StatusOK, 0, 0, 0, 0, 0})))
+	if err == nil || err.Error() != "Truncated frame" {
+		t.Errorf("short result: got error %v", err)
+	}
+}
+
+func TestShortFrameAllocatesLittle(t *testing.T) {
+	// a header claiming the largest frame, and then hardly anything
+	frame := []byte{0, 0, 0, 0, Version, OpMFetch, 0, 1}
+	binary.BigEndian.PutUint32(frame, MaxFrameSize)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ReadRequest(bufio.NewReader(bytes.NewReader(frame)))
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1024*1024 {
+		t.Errorf("allocated %d bytes for a frame that sent 4", allocated)
+	}
+}
+
+func TestWriteLimits(t *testing.T) {
+	w := bufio.NewWriter(&bytes.Buffer{})
+	err := WriteRequest(w, &Request{Op: OpFetch, Keys: []string{strings.Repeat("k", 0x10000)}})
+	if err == nil || err.Error() != "Key is too long" {
+		t.Errorf("long key: got error %v", err)
+	}
+	err = WriteRequest(w, &Request{Op: OpMFetch, Keys: make([]string, 0x10000)})
+	if err == nil || err.Error() != "Too many keys for one request" {
+		t.Errorf("many keys: got error %v", err)
+	}
+	err = WriteResponse(w, &Response{Results: make([]Result, 0x10000)})
+	if err == nil || err.Error() != "Too many results for one response" {
+		t.Errorf("many results: got error %v", err)
+	}
+	var buf bytes.Buffer
+	w = bufio.NewWriter(&buf)
+	err = WriteResponse(w, &Response{Results: []Result{{Value: strings.Repeat("v", MaxFrameSize)}}})
+	if err != ErrFrameTooLarge || buf.Len() != 0 || w.Buffered() != 0 {
+		t.Errorf("large frame: got error %v with %d bytes written", err, buf.Len()+w.Buffered())
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	if StatusError(StatusOK) != nil {
+		t.Error("StatusOK should not be an error")
+	}
+	if err := StatusError(StatusNotFound); err == nil || err.Error() != "Key not found" {
+		t.Errorf("StatusNotFound: got %v", err)
+	}
+	if err := StatusError(42); err == nil || err.Error() != "Unknown status 42" {
+		t.Errorf("status 42: got %v", err)
+	}
+}